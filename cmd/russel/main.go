@@ -19,6 +19,8 @@ var (
 	v    = flag.Bool(  "version", false, "Show the version")
 	maxE = flag.Int(   "maxE",    8,     "Max amount of compiler errors")
 	exec = flag.Bool(  "e",       true,  "Make the file executable")
+	incl = flag.String("I",       "",    "Module search paths, separated by '" +
+	                                     string(os.PathListSeparator) + "'")
 
 	args []string
 )
@@ -65,7 +67,7 @@ func usage() {
 	fmt.Printf("%v v%v.%v.%v\n\n", config.AsciiLogo,
 	           config.VersionMajor, config.VersionMinor, config.VersionPatch)
 	fmt.Printf( "Github: %v\n", config.GithubLink)
-	fmt.Printf( "Usage: %v [build [FILE] | run FILE] [OPTIONS]\n", os.Args[0])
+	fmt.Printf( "Usage: %v [build [FILE] | run FILE | intrinsics] [OPTIONS]\n", os.Args[0])
 	fmt.Println("Options:")
 	fmt.Println("  -help\n        Show this message")
 	fmt.Println("  -h    Alias for -help")
@@ -106,6 +108,28 @@ func build() {
 	compile(path, *out)
 }
 
+func intrinsics() {
+	if len(args) > 0 {
+		printError("Unexpected argument '%v'", args[0])
+		printTry("-h")
+		os.Exit(1)
+	}
+
+	names := compiler.IntrinsicNames()
+	sigs  := make([]string, len(names))
+	width := 0
+	for i, name := range names {
+		sigs[i] = compiler.GetIntrinsic(name).Signature(name)
+		if len(sigs[i]) > width {
+			width = len(sigs[i])
+		}
+	}
+
+	for i, name := range names {
+		fmt.Printf("%-*v  %v\n", width, sigs[i], compiler.GetIntrinsic(name).Code())
+	}
+}
+
 func compile(path, out string) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -114,7 +138,7 @@ func compile(path, out string) {
 		os.Exit(1)
 	}
 
-	c := compiler.New(string(data), path)
+	c := compiler.New(string(data), path, filepath.SplitList(*incl))
 
 	err = c.CompileInto(out, *exec)
 	if err != nil {
@@ -149,8 +173,9 @@ func main() {
 	}
 
 	switch mode {
-	case "run":   run()
-	case "build": build()
+	case "run":        run()
+	case "build":      build()
+	case "intrinsics": intrinsics()
 
 	default:
 		printError("Unknown mode '%v'", mode)
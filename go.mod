@@ -3,7 +3,8 @@ module github.com/LordOfTrident/russel
 go 1.18
 
 require (
-	github.com/avm-collection/agen v0.0.0-20230318192103-56f03e9e2a2a // indirect
-	github.com/avm-collection/anasm v1.20.10 // indirect
-	github.com/avm-collection/goerror v0.0.0-20230318192050-9b87b770297d // indirect
+	github.com/avm-collection/agen v0.0.0-20230318192103-56f03e9e2a2a
+	github.com/avm-collection/goerror v0.0.0-20230318192050-9b87b770297d
 )
+
+require github.com/avm-collection/anasm v1.20.10 // indirect
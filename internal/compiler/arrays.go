@@ -0,0 +1,194 @@
+package compiler
+
+import (
+	"github.com/avm-collection/goerror"
+	"github.com/avm-collection/agen"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/token"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+/*
+	Arrays are laid out like structures with all fields of the same type, so an array value takes
+	up the words of all its elements in order. The address of an element is
+
+		address of the array + index * size of the element
+*/
+
+func isArrayType(n *node.Type) bool {
+	return n != nil && n.Elem != nil && !n.Pointer
+}
+
+func (k *checker) resolveArrayType(n *node.Type) *value.Type {
+	elem := k.resolveType(n.Elem)
+	if elem == value.Void {
+		goerror.Error(n.Elem.Where, "Arrays can not hold 'void'")
+		return invalid
+	}
+
+	if n.Len <= 0 {
+		goerror.Error(n.Where, "Array length has to be positive, got %v", n.Len)
+		return invalid
+	} else if elem == invalid {
+		return invalid
+	}
+
+	return value.ArrayOf(elem, int(n.Len))
+}
+
+func (k *checker) checkArray(n *node.Array) *value.Type {
+	if len(n.Elems) == 0 {
+		goerror.Error(n.Where, "Array literals can not be empty")
+		goerror.NoteSuggestNewCode(n.Where, "Suggestion: declare the array without a value",
+		                           []string{"let array: [10]int"})
+		return invalid
+	}
+
+	elem := k.checkExpr(n.Elems[0])
+	for _, expr := range n.Elems[1:] {
+		if k.errorMismatchExpr(expr, elem, k.checkExpr(expr)) {
+			goerror.Note(n.Elems[0].NodeWhere(), "Type of the array elements decided here")
+		}
+	}
+
+	switch elem {
+	case invalid: return invalid
+	case value.Void:
+		goerror.Error(n.Elems[0].NodeWhere(), "Arrays can not hold 'void'")
+		return invalid
+	}
+
+	return value.ArrayOf(elem, len(n.Elems))
+}
+
+func (k *checker) checkIndex(n *node.Index, base *value.Type) *value.Type {
+	if t := k.checkExpr(n.Index); !isInt(t) {
+		k.errorMismatch(n.Index.NodeWhere(), value.Int, t)
+	}
+	if base == value.String {
+		return value.Char
+	}
+
+	base = pointee(base)
+	if base == invalid {
+		return invalid
+	} else if base.Kind != value.KindArray {
+		goerror.Error(n.Where, "Type '%v' can not be indexed", base)
+		return invalid
+	}
+
+	// Constant indexes are checked at compile time, the rest is not checked
+	if i, ok := constInt(n.Index); ok && (i < 0 || i >= int64(base.Len)) {
+		goerror.Error(n.Index.NodeWhere(), "Index %v is out of bounds of array type '%v'", i, base)
+	}
+
+	return base.Elem
+}
+
+func (k *checker) checkLen(n *node.FuncCall, args []*value.Type) *value.Type {
+	if !k.checkArgs(n, []*value.Type{invalid}, token.Where{}) {
+		return value.Int
+	}
+
+	if args[0] != invalid && args[0] != value.String && args[0].Kind != value.KindArray {
+		goerror.Error(n.Args[0].NodeWhere(), "Expected an array or a string, got '%v'", args[0])
+	}
+	return value.Int
+}
+
+// Words of a constant value, so a global initialized with it can be stored in the memory directly
+func constWords(n node.Expr) ([]agen.Word, bool) {
+	if i, ok := constInt(n); ok {
+		return []agen.Word{agen.Word(i)}, true
+	}
+
+	switch e := n.(type) {
+	case *node.Float: return []agen.Word{floatWord(e.Value)}, true
+	case *node.Bool:  return []agen.Word{boolToWord(e.Value)}, true
+	case *node.Char:  return []agen.Word{agen.Word(e.Value)}, true
+
+	case *node.Array:
+		words := []agen.Word{}
+		for _, elem := range e.Elems {
+			elemWords, ok := constWords(elem)
+			if !ok {
+				return nil, false
+			}
+
+			words = append(words, elemWords...)
+		}
+		return words, true
+
+	default: return nil, false
+	}
+}
+
+func (c *Compiler) compileArray(n *node.Array) {
+	for _, elem := range n.Elems {
+		c.compileExpr(elem)
+	}
+}
+
+// Adds the offset of the indexed element to the address of the array on the top of the stack
+func (c *Compiler) compileElemOffset(n *node.Index) {
+	size := agen.Word(c.types[n].Size())
+
+	if i, ok := constInt(n.Index); ok {
+		if offset := agen.Word(i) * size + agen.Word(c.types[n].ByteOffset()); offset != 0 {
+			c.a.AddInstWith("psh", offset)
+			c.a.AddInst(    "add")
+		}
+		return
+	}
+
+	c.compileExpr(n.Index)
+	c.a.AddInstWith("psh", size)
+	c.a.AddInst(    "mul")
+	c.a.AddInst(    "add")
+	c.compileByteOffset(c.types[n])
+}
+
+func (c *Compiler) compileIndex(n *node.Index) {
+	if c.types[n.Expr] == value.String {
+		c.compileStringIndex(n)
+		return
+	} else if c.isAddressable(n) {
+		c.compileAddr(n)
+		c.compileRead(c.types[n])
+		return
+	}
+
+	// Temporary arrays are stored in a memory of their own first, so they can be indexed. The index
+	// is evaluated before the array, so nothing can overwrite the memory before the read
+	base := c.types[n.Expr]
+	tmp  := c.a.AddMemoryInt(make([]agen.Word, base.Words()), agen.I64)
+
+	c.compileExpr(n.Index)
+	c.compileExpr(n.Expr)
+	c.a.AddInstWith("psh", tmp)
+	c.compileWrite(base)
+
+	c.a.AddInstWith("psh", agen.Word(base.Elem.Size()))
+	c.a.AddInst(    "mul")
+	c.a.AddInstWith("psh", tmp + agen.Word(base.Elem.ByteOffset()))
+	c.a.AddInst(    "add")
+	c.compileRead(base.Elem)
+}
+
+// The length of an array is known at compile time, the array is only evaluated if it is not a
+// variable
+func (c *Compiler) compileLen(n *node.FuncCall) {
+	arg := n.Args[0]
+	if c.types[arg] == value.String {
+		c.compileStringLen(arg)
+		return
+	} else if !c.isAddressable(arg) {
+		c.compileExpr(arg)
+		for i := 0; i < c.types[arg].Words(); i ++ {
+			c.a.AddInst("pop")
+		}
+	}
+
+	c.a.AddInstWith("psh", agen.Word(c.types[arg].Len))
+}
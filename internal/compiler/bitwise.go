@@ -0,0 +1,106 @@
+package compiler
+
+import (
+	"github.com/LordOfTrident/russel/internal/node"
+)
+
+/*
+	'and', 'or' and 'not' work with booleans, the bitwise intrinsics work with the bits of integers:
+
+		(band x 0xff)   (bor a b)   (bxor a b)   (bnot x)
+		(shl x 4)       (shr x 4)   (sar x 4)
+
+	'shr' shifts in zeros, 'sar' copies the sign bit. AVM has no instructions for 'bxor', 'bnot'
+	and 'sar', so they are built from the others. Integer intrinsics with constant arguments are
+	folded at compile time, so they can initialize globals and index arrays like literals
+*/
+
+// (sar a b) is (bnot (shr (bnot a) b)) for negative values, so the sign bits stay set
+func (c *Compiler) compileShiftRightArith() {
+	c.a.AddInstWith("swp", 0)                    //     swp 0        # value on the top
+	c.a.AddInstWith("dup", 0)                    //     dup 0
+	c.a.AddInstWith("psh", 0)                    //     psh 0
+	c.a.AddInst(    "les")                       //     les
+	negative := c.a.AddInst("jnz")               //     jnz negative
+	c.a.AddInstWith("swp", 0)                    //     swp 0
+	c.a.AddInst(    "bsr")                       //     bsr
+	end := c.a.AddInst("jmp")                    //     jmp end
+
+	c.a.GetInstAt(negative).Data = c.a.Label()   // negative:
+	c.a.AddInst(    "neg")                       //     neg
+	c.a.AddInst(    "dec")                       //     dec
+	c.a.AddInstWith("swp", 0)                    //     swp 0
+	c.a.AddInst(    "bsr")                       //     bsr
+	c.a.AddInst(    "neg")                       //     neg
+	c.a.AddInst(    "dec")                       //     dec
+	c.a.GetInstAt(end).Data = c.a.Label()        // end:
+}
+
+// Value of a constant integer expression
+func constInt(n node.Expr) (int64, bool) {
+	switch e := n.(type) {
+	case *node.Int: return e.Value, true
+
+	case *node.FuncCall:
+		if e.Module != nil || len(e.Args) == 0 {
+			return 0, false
+		}
+
+		args := make([]int64, len(e.Args))
+		for i, arg := range e.Args {
+			v, ok := constInt(arg)
+			if !ok {
+				return 0, false
+			}
+
+			args[i] = v
+		}
+		return foldInts(e.Name.Value, args)
+
+	default: return 0, false
+	}
+}
+
+// Evaluates an integer intrinsic, false if it is not one or its result depends on the runtime,
+// like division by zero
+func foldInts(name string, args []int64) (int64, bool) {
+	if name == "bnot" {
+		return ^args[0], len(args) == 1
+	}
+
+	result := args[0]
+	for _, arg := range args[1:] {
+		switch name {
+		case "+":    result += arg
+		case "-":    result -= arg
+		case "*":    result *= arg
+		case "band": result &= arg
+		case "bor":  result |= arg
+		case "bxor": result ^= arg
+
+		case "/", "%":
+			if arg == 0 {
+				return 0, false
+			} else if name == "/" {
+				result /= arg
+			} else {
+				result %= arg
+			}
+
+		case "shl", "shr", "sar":
+			if arg < 0 || arg > 63 {
+				return 0, false
+			}
+
+			switch name {
+			case "shl": result <<= arg
+			case "shr": result = int64(uint64(result) >> arg)
+			case "sar": result >>= arg
+			}
+
+		default: return 0, false
+		}
+	}
+
+	return result, len(args) > 1
+}
@@ -0,0 +1,846 @@
+package compiler
+
+import (
+	"github.com/avm-collection/goerror"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/token"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+// Type of expressions that already caused an error, so it is not reported again
+var invalid = &value.Type{Kind: value.KindInvalid}
+
+type checker struct {
+	c *Compiler
+
+	func_ *node.Func
+	loops []*node.Id // Labels of the loops around the checked statement, nil if not labeled
+
+	// Globals and structures whose types are known, or are being resolved
+	typed, resolving map[*Symbol]bool
+
+	// Errors which do not make the type of the expression invalid, so macro expansions can tell
+	// if they failed
+	mismatches int
+}
+
+func (c *Compiler) check() {
+	k := &checker{
+		c: c,
+
+		typed:     make(map[*Symbol]bool),
+		resolving: make(map[*Symbol]bool),
+	}
+
+	// Imported modules are checked before the modules importing them
+	for _, m := range c.modules {
+		c.inModule(m, func() {
+			k.checkModule(m)
+		})
+	}
+}
+
+func (k *checker) checkModule(m *Module) {
+	for _, stmt := range m.Program.List {
+		switch s := stmt.(type) {
+		case *node.Struct: k.resolveStruct(m.Scope.Symbols[s.Name.Value])
+		case *node.Enum:   k.resolveEnum(m.Scope.Symbols[s.Name.Value])
+		}
+	}
+
+	for _, stmt := range m.Program.List {
+		if f, ok := stmt.(*node.Func); ok {
+			k.checkFuncHead(f)
+		}
+	}
+
+	for _, stmt := range m.Program.List {
+		switch s := stmt.(type) {
+		case *node.Let:   k.globalType(m.Scope.Symbols[s.Name.Value])
+		case *node.Func:  k.checkFunc(s)
+		case *node.Macro: k.checkMacroParams(s)
+		}
+	}
+
+	k.c.warnUnused(m.Scope)
+}
+
+func (k *checker) resolveType(n *node.Type) *value.Type {
+	n.Type = k.typeFromName(n)
+	return n.Type
+}
+
+func (k *checker) typeFromName(n *node.Type) *value.Type {
+	if n.Pointer {
+		return k.resolvePointerType(n)
+	} else if n.Elem != nil {
+		return k.resolveArrayType(n)
+	} else if n.Module != nil {
+		sym := k.lookupQualified(n.Module, &node.Id{Where: n.Where, Value: n.Name})
+		if sym == nil {
+			return invalid
+		}
+
+		return k.symbolType(n, sym)
+	}
+
+	if t, ok := value.FromName(n.Name); ok {
+		return t
+	}
+
+	sym := k.c.scope.Lookup(n.Name)
+	if sym == nil {
+		goerror.Error(n.Where, "Unknown type '%v'", n.Name)
+
+		names := append(value.Names(), k.c.scope.Names(func(sym *Symbol) bool {
+			return sym.Kind == SymbolStruct || sym.Kind == SymbolEnum
+		})...)
+
+		similar := getMostSimilarName(n.Name, names)
+		if len(similar) > 0 {
+			goerror.NoteSuggestName(n.Where, similar)
+		}
+		return invalid
+	}
+
+	sym.Used = true
+	return k.symbolType(n, sym)
+}
+
+func (k *checker) symbolType(n *node.Type, sym *Symbol) *value.Type {
+	if sym.Kind != SymbolStruct && sym.Kind != SymbolEnum {
+		goerror.Error(n.Where, "%v '%v' is not a type", capitalize(sym.Kind.String()), n.String())
+		goerror.Note(sym.Where, "Defined here")
+		return invalid
+	}
+
+	return sym.Type
+}
+
+// Reports a type mismatch, unless one of the types is already invalid
+func (k *checker) errorMismatch(where token.Where, expected, got *value.Type) bool {
+	if expected == got || expected == invalid || got == invalid || convertible(expected, got) {
+		return false
+	}
+
+	goerror.Error(where, "Expected type '%v', got '%v'", expected, got)
+	k.mismatches ++
+	return true
+}
+
+// Reports a type mismatch of an expression, constant integers take the expected type
+func (k *checker) errorMismatchExpr(n node.Expr, expected, got *value.Type) bool {
+	if !k.errorMismatch(n.NodeWhere(), expected, k.constAs(n, got, expected)) {
+		return false
+	}
+
+	if expected.Kind == value.KindInt && got.Kind == value.KindInt {
+		goerror.NoteSuggestNewCode(n.NodeWhere(), "Suggestion: cast it",
+		                           []string{"(" + expected.Name + " " + n.String() + ")"})
+	}
+	return true
+}
+
+func (k *checker) checkVarType(where token.Where, name string, t *value.Type) {
+	if t == value.Void {
+		goerror.Error(where, "Variable '%v' can not be of type 'void'", name)
+	}
+}
+
+func (k *checker) checkFuncHead(f *node.Func) {
+	for _, param := range f.Params {
+		if param.Type != nil {
+			k.checkVarType(param.Name.Where, param.Name.Value, k.resolveType(param.Type))
+		}
+	}
+
+	if f.Type != nil {
+		k.resolveType(f.Type)
+	}
+
+	if f.Attrs & node.AttrInterrupt != 0 {
+		k.checkInterruptHead(f)
+	}
+
+	if f.Name.Value == MainFuncName {
+		if len(f.Params) > 0 {
+			goerror.Error(f.Params[0].Where, "Entry function '%v' can not take parameters",
+			              MainFuncName)
+		}
+
+		if f.Type != nil && f.Type.Type != value.Int && f.Type.Type != invalid {
+			goerror.Error(f.Type.Where, "Entry function '%v' has to return 'int' or nothing",
+			              MainFuncName)
+		}
+	}
+}
+
+func funcType(f *node.Func) *value.Type {
+	if f.Type == nil {
+		return value.Void
+	}
+
+	return f.Type.Type
+}
+
+func (k *checker) globalType(sym *Symbol) *value.Type {
+	if k.typed[sym] {
+		return sym.Type
+	}
+
+	// Initialization cycles are reported by the code generator, until then the global has no type
+	if k.resolving[sym] {
+		return invalid
+	}
+	sym.Type = invalid
+
+	// Globals are initialized outside of any function
+	func_ := k.func_
+	k.func_ = nil
+
+	k.resolving[sym] = true
+	k.c.inModule(sym.Owner, func() {
+		sym.Type = k.checkLetType(sym.Let)
+	})
+	k.resolving[sym] = false
+	k.typed[sym]     = true
+
+	k.func_ = func_
+	return sym.Type
+}
+
+func (k *checker) checkLetType(n *node.Let) *value.Type {
+	t := invalid
+	if n.Type != nil {
+		t = k.resolveType(n.Type)
+	}
+
+	if n.Expr != nil {
+		exprType := k.checkExpr(n.Expr)
+
+		if n.Type == nil {
+			t = exprType
+		} else if k.errorMismatchExpr(n.Expr, t, exprType) {
+			goerror.Note(n.Type.Where, "Type declared here")
+		}
+	} else if n.Type == nil {
+		goerror.Error(n.Name.Where, "Variable '%v' needs a type or an initializer", n.Name.Value)
+		goerror.NoteSuggestNewCode(n.Name.Where, "Suggestion: declare the type", []string{
+			"let " + n.Name.Value + ": int",
+		})
+	}
+
+	if t != invalid {
+		k.checkVarType(n.Name.Where, n.Name.Value, t)
+	}
+	return t
+}
+
+func (k *checker) popScope() {
+	k.c.warnUnused(k.c.popScope())
+}
+
+// Resolves a name to a symbol, marking it as used
+func (k *checker) lookup(name string) *Symbol {
+	sym := k.c.scope.Lookup(name)
+	if sym == nil {
+		return nil
+	}
+
+	sym.Used = true
+	if sym.Kind == SymbolGlobal {
+		k.globalType(sym)
+	}
+	return sym
+}
+
+// Whether the field access is a qualified name like 'module.name'
+func (k *checker) isQualified(n *node.FieldAccess) bool {
+	id, ok := n.Expr.(*node.Id)
+	if !ok {
+		return false
+	}
+
+	sym := k.c.scope.Lookup(id.Value)
+	return sym != nil && sym.Kind == SymbolModule
+}
+
+// Resolves a qualified name like 'module.name', marking both as used
+func (k *checker) lookupQualified(module, name *node.Id) *Symbol {
+	modSym := k.lookup(module.Value)
+	if modSym == nil {
+		goerror.Error(module.Where, "Unknown module '%v'", module.Value)
+
+		similar := getMostSimilarName(module.Value, k.c.scope.Names(func(sym *Symbol) bool {
+			return sym.Kind == SymbolModule
+		}))
+		if len(similar) > 0 {
+			goerror.NoteSuggestName(module.Where, similar)
+		}
+		return nil
+	} else if modSym.Kind != SymbolModule {
+		goerror.Error(module.Where, "%v '%v' is not a module", capitalize(modSym.Kind.String()),
+		              module.Value)
+		goerror.Note(modSym.Where, "Defined here")
+		return nil
+	}
+
+	sym := modSym.Module.Lookup(name.Value)
+	if sym == nil {
+		goerror.Error(name.Where, "Module '%v' has no '%v'", modSym.Name, name.Value)
+
+		similar := getMostSimilarName(name.Value, modSym.Module.Names())
+		if len(similar) > 0 {
+			goerror.NoteSuggestName(name.Where, similar)
+		}
+		return nil
+	}
+
+	sym.Used = true
+	if sym.Kind == SymbolGlobal {
+		k.globalType(sym)
+	}
+	return sym
+}
+
+func (k *checker) checkFunc(f *node.Func) {
+	k.func_ = f
+	k.c.pushScope()
+
+	for _, param := range f.Params {
+		t := invalid
+		if param.Type != nil {
+			t = param.Type.Type
+		}
+
+		k.c.define(&Symbol{Kind: SymbolParam, Name: param.Name.Value, Where: param.Name.Where,
+		                   Type: t})
+	}
+
+	k.checkStmts(f.Body)
+
+	if f.Type != nil && !alwaysReturns(f.Body) {
+		goerror.Error(f.Name.Where, "Function '%v' can reach its end without returning a value",
+		              f.Name.Value)
+		goerror.Note(f.Type.Where, "Return type declared here")
+	}
+
+	k.popScope()
+	k.func_ = nil
+}
+
+func (k *checker) checkStmts(n *node.Stmts) {
+	if n == nil {
+		return
+	}
+
+	k.c.pushScope()
+	for _, stmt := range n.List {
+		k.checkStmt(stmt)
+	}
+	k.popScope()
+}
+
+func (k *checker) checkStmt(n node.Stmt) {
+	switch s := n.(type) {
+	case *node.ExprStmt:  k.checkExpr(s.Expr)
+	case *node.Let:       k.checkLet(s)
+	case *node.Macro:     k.checkMacro(s)
+	case *node.Return:    k.checkReturn(s)
+	case *node.Assign:    k.checkAssign(s)
+	case *node.Increment: k.checkIncrement(s)
+	case *node.Break:     k.checkJump("break", s.Where, s.Label)
+	case *node.Continue:  k.checkJump("continue", s.Where, s.Label)
+
+	case *node.If:
+		if s.Var != nil {
+			k.c.pushScope()
+			defer k.popScope()
+
+			k.checkLet(s.Var)
+		}
+
+		k.checkCond(s.Cond)
+		k.checkStmts(s.Then)
+		k.checkStmts(s.Else)
+
+	case *node.Match: k.checkMatch(s)
+
+	case *node.While:
+		k.checkCond(s.Cond)
+
+		k.pushLoop(s.Label)
+		k.checkStmts(s.Body)
+		k.popLoop()
+
+	case *node.For:
+		k.c.pushScope()
+		defer k.popScope()
+
+		if s.Var != nil {
+			k.checkLet(s.Var)
+		}
+
+		k.checkCond(s.Cond)
+
+		k.pushLoop(s.Label)
+		k.checkStmt(s.Last)
+		k.checkStmts(s.Body)
+		k.popLoop()
+	}
+}
+
+func (k *checker) pushLoop(label *node.Id) {
+	if label != nil {
+		if outer := k.findLoop(label.Value); outer != nil {
+			goerror.Error(label.Where, "Label '%v' is already used by an enclosing loop",
+			              label.Value)
+			goerror.Note(outer.Where, "Used here")
+		}
+	}
+
+	k.loops = append(k.loops, label)
+}
+
+func (k *checker) popLoop() {
+	k.loops = k.loops[:len(k.loops) - 1]
+}
+
+// Label of the innermost loop with the name
+func (k *checker) findLoop(name string) *node.Id {
+	for i := len(k.loops) - 1; i >= 0; i -- {
+		if label := k.loops[i]; label != nil && label.Value == name {
+			return label
+		}
+	}
+
+	return nil
+}
+
+// Checks that a 'break' or 'continue' has a loop to jump out of
+func (k *checker) checkJump(what string, where token.Where, label *node.Id) {
+	if len(k.loops) == 0 {
+		goerror.Error(where, "'%v' outside of a loop", what)
+		return
+	} else if label == nil || k.findLoop(label.Value) != nil {
+		return
+	}
+
+	goerror.Error(label.Where, "No enclosing loop is labeled '%v'", label.Value)
+
+	labels := []string{}
+	for _, loop := range k.loops {
+		if loop != nil {
+			labels = append(labels, loop.Value)
+		}
+	}
+
+	similar := getMostSimilarName(label.Value, labels)
+	if len(similar) > 0 {
+		goerror.NoteSuggestName(label.Where, similar)
+	}
+}
+
+func (k *checker) checkCond(n node.Expr) {
+	k.errorMismatch(n.NodeWhere(), value.Bool, k.checkExpr(n))
+}
+
+func (k *checker) checkLet(n *node.Let) {
+	// Define the local after checking the expression, so it cannot refer to itself
+	t := k.checkLetType(n)
+	k.c.define(&Symbol{Kind: SymbolLocal, Name: n.Name.Value, Where: n.Name.Where, Type: t})
+}
+
+func (k *checker) checkMacro(n *node.Macro) {
+	k.checkMacroParams(n)
+	k.c.define(k.c.newMacro(n))
+}
+
+func (k *checker) checkReturn(n *node.Return) {
+	f := k.func_
+	if n.Expr == nil {
+		if f.Type != nil {
+			goerror.Error(n.Where, "Function '%v' expects a return value of type '%v'",
+			              f.Name.Value, f.Type.Name)
+			goerror.Note(f.Type.Where, "Return type declared here")
+		}
+		return
+	}
+
+	t := k.checkExpr(n.Expr)
+	if f.Type == nil {
+		goerror.Error(n.Expr.NodeWhere(), "Function '%v' does not return a value", f.Name.Value)
+		goerror.Note(f.Name.Where, "Declared without a return type here")
+	} else if k.errorMismatchExpr(n.Expr, f.Type.Type, t) {
+		goerror.Note(f.Type.Where, "Return type declared here")
+	}
+}
+
+// Checks that a name refers to a variable which can be written into
+func (k *checker) checkVarTarget(name *node.Id) *Symbol {
+	sym := k.c.scope.Lookup(name.Value)
+	if sym == nil {
+		k.c.errorUnknownVar(name)
+		return nil
+	}
+
+	return k.checkAssignable(name, sym)
+}
+
+func (k *checker) checkAssignable(name *node.Id, sym *Symbol) *Symbol {
+	if !sym.IsVar() {
+		goerror.Error(name.Where, "Can not assign to %v '%v'", sym.Kind, name.Value)
+		goerror.Note(sym.Where, "Defined here")
+		return nil
+	}
+
+	if sym.Kind == SymbolGlobal {
+		k.globalType(sym)
+	}
+	return sym
+}
+
+// Checks that an expression is a variable or a field of one, returning the variable
+func (k *checker) checkTarget(n node.Expr) (t *value.Type, sym *Symbol) {
+	switch e := n.(type) {
+	case *node.Id:
+		t = invalid
+		if sym = k.checkVarTarget(e); sym != nil {
+			t = sym.Type
+		}
+
+	case *node.FieldAccess:
+		if k.isQualified(e) {
+			t = invalid
+			if sym = k.lookupQualified(e.Expr.(*node.Id), e.Field); sym != nil {
+				if sym = k.checkAssignable(e.Field, sym); sym != nil {
+					t = sym.Type
+				}
+			}
+			break
+		}
+
+		t, sym = k.checkTarget(e.Expr)
+		t      = k.checkField(e, k.checkDeref(t, sym))
+
+	case *node.Index:
+		t, sym = k.checkTarget(e.Expr)
+		if t == value.String {
+			goerror.Error(e.Where, "Characters of a string can not be assigned")
+			goerror.Note(e.Where, "Strings do not own their characters")
+			return invalid, nil
+		}
+
+		t = k.checkIndex(e, k.checkDeref(t, sym))
+
+	case *node.Slice:
+		goerror.Error(e.Where, "Can not assign to a slice")
+		return invalid, nil
+
+	default: panic("Unreachable")
+	}
+
+	k.c.types[n] = t
+	return
+}
+
+// Writing through a pointer reads the pointer
+func (k *checker) checkDeref(t *value.Type, sym *Symbol) *value.Type {
+	if t.Kind == value.KindPointer && sym != nil {
+		sym.Used = true
+	}
+	return t
+}
+
+func (k *checker) checkAssign(n *node.Assign) {
+	exprType := k.checkExpr(n.Expr)
+
+	t, sym := k.checkTarget(n.Target)
+	if sym == nil {
+		return
+	}
+
+	if k.errorMismatchExpr(n.Expr, t, exprType) {
+		goerror.Note(sym.Where, "Variable '%v' declared here", sym.Name)
+	}
+}
+
+func (k *checker) checkIncrement(n *node.Increment) {
+	t, sym := k.checkTarget(n.Target)
+	if sym == nil {
+		return
+	}
+
+	// Incrementing reads the variable too
+	sym.Used = true
+	if !isInt(t) {
+		k.errorMismatch(n.Target.NodeWhere(), value.Int, t)
+		goerror.Note(sym.Where, "Variable '%v' declared here", sym.Name)
+	}
+}
+
+// Checks an expression, remembering its type for the code generator
+func (k *checker) checkExpr(n node.Expr) (t *value.Type) {
+	switch e := n.(type) {
+	case *node.Int:         t = literalType(e)
+	case *node.Float:       t = value.Float
+	case *node.Bool:        t = value.Bool
+	case *node.Char:        t = value.Char
+	case *node.String:      t = value.String
+	case *node.FuncCall:    t = k.checkFuncCall(e)
+	case *node.Id:          t = k.checkId(e)
+	case *node.FieldAccess: t = k.checkFieldAccess(e)
+	case *node.Array:       t = k.checkArray(e)
+	case *node.Index:       t = k.checkIndex(e, k.checkExpr(e.Expr))
+	case *node.Slice:       t = k.checkSlice(e)
+	case *node.MacroArg:    t = k.checkMacroArg(e)
+
+	default: panic("TODO: Unimplemented")
+	}
+
+	k.c.types[n] = t
+	return
+}
+
+func (k *checker) checkId(n *node.Id) *value.Type {
+	sym := k.lookup(n.Value)
+	if sym == nil {
+		k.c.errorUnknownVar(n)
+		return invalid
+	}
+
+	return k.checkSymbol(n, sym)
+}
+
+func (k *checker) checkFieldAccess(n *node.FieldAccess) *value.Type {
+	if sym := k.enumOf(n.Expr); sym != nil {
+		return k.checkMember(n, sym)
+	} else if !k.isQualified(n) {
+		return k.checkField(n, k.checkExpr(n.Expr))
+	}
+
+	sym := k.lookupQualified(n.Expr.(*node.Id), n.Field)
+	if sym == nil {
+		return invalid
+	}
+
+	return k.checkSymbol(n.Field, sym)
+}
+
+// Type of a variable or a macro expansion
+func (k *checker) checkSymbol(n *node.Id, sym *Symbol) *value.Type {
+	switch sym.Kind {
+	case SymbolMacro: return k.checkMacroExpansion(n, sym)
+	case SymbolFunc, SymbolStruct, SymbolEnum, SymbolModule:
+		goerror.Error(n.Where, "%v '%v' used as a variable", capitalize(sym.Kind.String()), n.Value)
+		goerror.Note(sym.Where, "Defined here")
+		return invalid
+
+	default: return sym.Type
+	}
+}
+
+func (k *checker) checkMacroExpansion(n *node.Id, sym *Symbol) *value.Type {
+	if sym.expanding {
+		goerror.Error(n.Where, "Macro '%v' expands to itself", n.Value)
+		goerror.Note(sym.Where, "Defined here")
+		return invalid
+	}
+
+	if len(sym.Macro.Params) > 0 {
+		goerror.Error(n.Where, "Macro '%v' expects %v arguments, it has to be called",
+		              n.Value, len(sym.Macro.Params))
+		goerror.Note(sym.Where, "Defined here")
+		return invalid
+	}
+
+	return k.expand(n.Where, sym, func() (t *value.Type) {
+		k.c.expandIn(sym, func() {
+			t = k.checkExpr(sym.Macro.Expr)
+		})
+		return
+	})
+}
+
+func (k *checker) checkArgs(n *node.FuncCall, types []*value.Type, where token.Where) bool {
+	if len(n.Args) != len(types) {
+		goerror.Error(n.Where, "Function '%v' expects %v arguments, got %v",
+		              n.Name.Value, len(types), len(n.Args))
+		if where.Len > 0 {
+			goerror.Note(where, "Defined here")
+		}
+
+		k.mismatches ++
+		return false
+	}
+
+	return true
+}
+
+func (k *checker) checkArity(n *node.FuncCall, in *Intrinsic) bool {
+	if !in.Variadic {
+		return k.checkArgs(n, in.Args, token.Where{})
+	} else if len(n.Args) < len(in.Args) {
+		goerror.Error(n.Where, "Function '%v' expects at least %v arguments, got %v",
+		              n.Name.Value, len(in.Args), len(n.Args))
+		k.mismatches ++
+		return false
+	}
+
+	return true
+}
+
+func (k *checker) checkFuncCall(n *node.FuncCall) *value.Type {
+	name := n.Name.Value
+	if n.Module == nil && name == "addr" {
+		return k.checkAddrOf(n)
+	}
+
+	args := make([]*value.Type, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = k.checkExpr(arg)
+	}
+
+	if n.Module != nil {
+		sym := k.lookupQualified(n.Module, n.Name)
+		if sym == nil {
+			return invalid
+		}
+
+		return k.checkCall(n, sym, args)
+	}
+
+	if t := castType(n); t != nil {
+		return k.checkCast(n, t, args)
+	} else if name == "len" {
+		return k.checkLen(n, args)
+	} else if name == "raise" {
+		return k.checkRaise(n, args)
+	} else if name == "name_of" {
+		return k.checkNameOf(n, args)
+	} else if name == "==" || name == "/=" {
+		if !k.checkArgs(n, []*value.Type{invalid, invalid}, token.Where{}) {
+			return invalid
+		}
+
+		args[0] = k.constAs(n.Args[0], args[0], args[1])
+		if k.errorMismatchExpr(n.Args[1], args[0], args[1]) {
+			return value.Bool
+		} else if !isComparable(args[0]) {
+			goerror.Error(n.Args[0].NodeWhere(), "Values of type '%v' can not be compared", args[0])
+		}
+		return value.Bool
+	}
+
+	if t, ok := k.checkPointerCall(n, args); ok {
+		return t
+	}
+
+	// Characters are ordered by their codes, strings by their characters, enumerations by the
+	// values of their members
+	if isOrdering(name) && len(args) == 2 && (args[0] == value.Char || args[0] == value.String ||
+	                                          args[0].Kind == value.KindEnum) {
+		k.errorMismatch(n.Args[1].NodeWhere(), args[0], args[1])
+		return value.Bool
+	}
+
+	if _, ok := floatInsts[name]; ok && len(args) > 0 && args[0] == value.Float {
+		ret := value.Float
+		if isOrdering(name) {
+			ret = value.Bool
+		}
+
+		if k.checkArity(n, intrinsics[name]) {
+			for i, arg := range args[1:] {
+				k.errorMismatch(n.Args[i + 1].NodeWhere(), value.Float, arg)
+			}
+		}
+		return ret
+	}
+
+	if intrinsic, ok := intrinsics[name]; ok {
+		if !k.checkArity(n, intrinsic) {
+			return intrinsic.Ret
+		}
+
+		operand := k.operandType(n, intrinsic, args)
+		for i, arg := range args {
+			expected := intrinsic.ArgType(i)
+			if expected == value.Int {
+				expected = operand
+			}
+
+			k.errorMismatchExpr(n.Args[i], expected, arg)
+		}
+
+		if intrinsic.Ret == value.Int {
+			return operand
+		}
+		return intrinsic.Ret
+	}
+
+	sym := k.lookup(name)
+	if sym == nil {
+		goerror.Error(n.Name.Where, "Unknown function '%v'", name)
+
+		similar := getMostSimilarName(name, append(k.c.getFuncNames(), IntrinsicNames()...))
+		if len(similar) > 0 {
+			goerror.NoteSuggestName(n.Name.Where, similar)
+		}
+		return invalid
+	}
+
+	return k.checkCall(n, sym, args)
+}
+
+func isComparable(t *value.Type) bool {
+	switch t {
+	case value.Float, value.Bool, value.Char, value.String, invalid: return true
+
+	default:
+		return t.Kind == value.KindPointer || t.Kind == value.KindInt || t.Kind == value.KindEnum
+	}
+}
+
+func isOrdering(name string) bool {
+	switch name {
+	case ">", ">=", "<", "<=": return true
+
+	default: return false
+	}
+}
+
+func (k *checker) checkCall(n *node.FuncCall, sym *Symbol, args []*value.Type) *value.Type {
+	if sym.Kind == SymbolStruct {
+		return k.checkConstruct(n, sym, args)
+	} else if sym.Kind == SymbolMacro {
+		return k.checkMacroCall(n, sym, args)
+	} else if sym.Kind != SymbolFunc {
+		goerror.Error(n.Name.Where, "%v '%v' is not a function", capitalize(sym.Kind.String()),
+		              n.Name.Value)
+		goerror.Note(sym.Where, "Defined here")
+		return invalid
+	}
+
+	f := sym.Func
+	if f.Attrs & node.AttrInterrupt != 0 {
+		k.errorInterruptCall(n, sym)
+		return value.Void
+	}
+
+	params := make([]*value.Type, len(f.Params))
+	for i, param := range f.Params {
+		params[i] = param.Type.Type
+	}
+
+	if k.checkArgs(n, params, f.Name.Where) {
+		for i, t := range params {
+			if k.errorMismatchExpr(n.Args[i], t, args[i]) {
+				goerror.Note(f.Params[i].Where, "Parameter declared here")
+			}
+		}
+	}
+
+	return funcType(f)
+}
@@ -9,7 +9,7 @@ import (
 
 	"github.com/LordOfTrident/russel/internal/parser"
 	"github.com/LordOfTrident/russel/internal/node"
-	"github.com/LordOfTrident/russel/internal/token"
+	"github.com/LordOfTrident/russel/internal/value"
 )
 
 // https://en.wikipedia.org/wiki/Levenshtein_distance
@@ -63,83 +63,135 @@ func getMostSimilarName(name string, names []string) string {
 
 const MainFuncName = "main"
 
-type Func struct {
-	Used  bool
-	Addr  agen.Word
-	Node *node.Func
-}
-
-type Var struct {
-	Used bool
-	Addr agen.Word
-}
-
-type Macro struct {
-	Used bool
-	Expr node.Expr
-}
-
 type Call struct {
-	Name string
-	Addr agen.Word
+	Func *Symbol
+	Addr  agen.Word
 }
 
 type Compiler struct {
 	p *parser.Parser
 	a *agen.AGEN
 
-	funcs  map[string]Func
-	vars   map[string]Var
-	macros map[string]Macro
+	path string
+
+	scope *Scope
+
+	// Modules in the order they have to be checked in, the root module is the last one
+	root, module *Module
+	modules      []*Module
+
+	searchPaths []string
+	loaded      map[string]*Module // By absolute path
+	imports     map[*node.Import]*Module
 
-	toCompile     []Func
+	structs map[*value.Type]*Symbol
+
+	// Call instructions of the used helpers, patched once the helpers are compiled
+	helperCalls map[string][]agen.Word
+
+	// Expanded macro calls, filled in by the checker
+	expansions map[*node.FuncCall]node.Expr
+	callers    *caller
+
+	interrupts map[int64]*Symbol // Handlers by the number of their interrupt
+
+	toCompile     []*Symbol
 	deferredCalls []Call
 
-	inLoop bool
+	// Globals with initializers that have to be evaluated on startup
+	toInit []*Symbol
+
+	// Types of expressions, filled in by the checker
+	types map[node.Expr]*value.Type
+
+	fp, sp agen.Word
+	stack  agen.Word // Start of the frame stack
+	frame *Frame
+
+	loops []*Loop // Loops around the compiled statement, the innermost one last
+}
+
+// Loop a 'break' or 'continue' can jump out of
+type Loop struct {
+	Label *node.Id
+
 	breaks, continues []agen.Word
 }
 
-func New(input, path string) *Compiler {
+// Imported modules are searched for relative to the importing file first, then in the search paths
+func New(input, path string, searchPaths []string) *Compiler {
 	c := &Compiler{
 		p: parser.New(input, path),
 		a: agen.New(),
 
-		funcs:  make(map[string]Func),
-		vars:   make(map[string]Var),
-		macros: make(map[string]Macro),
+		path: path,
+
+		searchPaths: searchPaths,
+		loaded:      make(map[string]*Module),
+		imports:     make(map[*node.Import]*Module),
+
+		structs:     make(map[*value.Type]*Symbol),
+		types:       make(map[node.Expr]*value.Type),
+		helperCalls: make(map[string][]agen.Word),
+		expansions:  make(map[*node.FuncCall]node.Expr),
+		interrupts:  make(map[int64]*Symbol),
 	}
 
+	c.fp = c.a.AddMemoryInt([]agen.Word{0}, agen.I64)
+	c.sp = c.a.AddMemoryInt([]agen.Word{0}, agen.I64)
+
+	// Prologues check the end of the frame stack, so it is laid out before the code
+	c.stack = c.a.AddMemoryInt(make([]agen.Word, FrameStackSize / agen.WordSize), agen.I64)
+
 	return c
 }
 
 func (c *Compiler) CompileInto(path string, exec bool) error {
-	program := c.p.Parse()
-	if goerror.Happened() {
+	if c.root = c.loadModule(c.p, c.path); goerror.Happened() {
 		os.Exit(1)
 	}
 
-	if c.compile(program); goerror.Happened() {
+	for _, m := range c.modules {
+		c.inModule(m, func() {
+			c.register(m.Program)
+		})
+	}
+
+	if c.checkEntry(); goerror.Happened() {
+		os.Exit(1)
+	}
+
+	if c.check(); goerror.Happened() {
+		os.Exit(1)
+	}
+
+	if c.inModule(c.root, c.compile); goerror.Happened() {
 		os.Exit(1)
 	}
 
 	return c.a.CreateExecAVM(path, exec)
 }
 
-func (c *Compiler) compile(program *node.Stmts) {
+func (c *Compiler) register(program *node.Stmts) {
 	for _, stmt := range program.List {
 		switch s := stmt.(type) {
-		case *node.Func:  c.registerFunc(s)
-		case *node.Macro: c.registerMacro(s)
-		case *node.Let:   c.registerVar(s)
+		case *node.Func:   c.registerFunc(s)
+		case *node.Macro:  c.registerMacro(s)
+		case *node.Let:    c.registerVar(s)
+		case *node.Struct: c.registerStruct(s)
+		case *node.Enum:   c.registerEnum(s)
+		case *node.Import: c.registerImport(s)
+		case *node.Module:
 
 		default: panic("TODO: Unimplemented")
 		}
 	}
+}
 
-	main, ok := c.funcs[MainFuncName]
-	if !ok {
+func (c *Compiler) checkEntry() {
+	if main := c.root.Scope.Symbols[MainFuncName]; main == nil || main.Kind != SymbolFunc {
 		goerror.SimpleError("Missing entry function '%v'", MainFuncName)
-		goerror.NoteSuggestNewCode(c.p.WhereFileEnd, "Suggestion: add", []string{
+		goerror.NoteSuggestNewCode(c.root.End, "Suggestion: add", []string{
 			"proc (main) -> int {",
 			"    # Put your entry code here",
 			"",
@@ -147,69 +199,128 @@ func (c *Compiler) compile(program *node.Stmts) {
 			"}",
 		})
 	}
+}
 
-	c.compileFunc(main)
-
-	for name, func_ := range c.funcs {
-		if !func_.Used {
-			goerror.Warning(func_.Node.Where, "Unused function '%v'", name)
-			continue
+func (c *Compiler) compile() {
+	for _, m := range c.modules {
+		for _, stmt := range m.Program.List {
+			if let, ok := stmt.(*node.Let); ok {
+				c.layoutVar(m.Scope.Symbols[let.Name.Value])
+			}
 		}
 	}
 
+	main := c.root.Scope.Symbols[MainFuncName]
+	c.compileFunc(main)
+	c.compileInterrupts()
+
 	c.a.SetEntryHere()
+	c.compileFrameStack()
+	c.compileGlobalsInit()
 	c.a.AddInstWith("cal", main.Addr)
-	c.a.AddInstWith("psh", 0)
-	c.a.AddInst(    "hlt")
+	if main.Func.Type == nil {
+		c.a.AddInstWith("psh", 0)
+	}
+	c.a.AddInst("hlt")
+
+	// Functions called by the initializers of globals
+	c.compileQueued()
+	c.compileHelpers()
+
+	// Functions of imported modules are there for the modules importing them
+	for name, sym := range c.root.Scope.Symbols {
+		if sym.Kind == SymbolFunc && !sym.compiled {
+			goerror.Warning(sym.Where, "Unused function '%v'", name)
+		}
+	}
 }
 
-func (c *Compiler) checkNameExists(where token.Where, name string) bool {
-	if prev, ok := c.funcs[name]; ok {
-		goerror.Error(where, "Function '%v' redefined", name)
-		goerror.Note(prev.Node.Where, "Previously defined here")
-		return true
+func (c *Compiler) registerFunc(n *node.Func) {
+	sym := &Symbol{Kind: SymbolFunc, Name: n.Name.Value, Where: n.Where, Owner: c.module, Func: n}
+	if c.define(sym) {
+		c.registerInterrupt(sym)
 	}
+}
 
-	return false
+func (c *Compiler) registerMacro(n *node.Macro) {
+	c.define(c.newMacro(n))
 }
 
-func (c *Compiler) registerFunc(n *node.Func) {
+func (c *Compiler) newMacro(n *node.Macro) *Symbol {
+	return &Symbol{Kind: SymbolMacro, Name: n.Name.Value, Where: n.Where, Owner: c.module,
+	               Scope: c.scope, Macro: n}
+}
+
+func (c *Compiler) registerVar(n *node.Let) {
 	name := n.Name.Value
-	if (c.checkNameExists(n.Where, name)) {
+	sym  := &Symbol{Kind: SymbolGlobal, Name: name, Where: n.Name.Where, Owner: c.module, Let: n}
+	if !c.define(sym) {
 		return
 	}
 
-	if name != MainFuncName && n.Type != nil {
-		panic("TODO: Return type not allowed for functions except 'main'")
+	// Arrays are often too big to initialize with a literal, so they are zeroed without a warning
+	if n.Expr == nil && !isArrayType(n.Type) {
+		goerror.Warning(n.Name.Where, "Global variable '%v' is not initialized, defaulting to 0",
+		                name)
+	}
+}
+
+// Globals are laid out once their types are known. Constant initializers go straight into the
+// memory, the rest is initialized on startup
+func (c *Compiler) layoutVar(sym *Symbol) {
+	if words, ok := constWords(sym.Let.Expr); ok {
+		sym.Addr = c.a.AddMemoryInt(words, agen.I64)
+		return
 	}
 
-	c.funcs[n.Name.Value] = Func{Node: n}
+	sym.Addr = c.a.AddMemoryInt(make([]agen.Word, sym.Type.Words()), agen.I64)
+	if sym.Let.Expr != nil {
+		c.toInit = append(c.toInit, sym)
+	}
 }
 
-func (c *Compiler) registerMacro(n *node.Macro) {
-	panic("TODO: Unimplemented")
+func (c *Compiler) compileFuncBody(f *node.Func) {
+	prev := c.frame
+	c.frame = c.newFrame(f)
+	c.pushScope()
+
+	c.compilePrologue(f)
+	c.compileStmts(f.Body)
+	c.compileEpilogue()
+
+	c.popScope()
+	for _, sizeAddr := range c.frame.sizeAddrs {
+		c.a.GetInstAt(sizeAddr).Data = c.frame.size
+	}
+	c.frame = prev
 }
 
-func (c *Compiler) registerVar(n *node.Let) {
-	panic("TODO: Unimplemented")
+func (c *Compiler) compileInlineFunc(sym *Symbol) {
+	sym.compiled = true
+
+	// Inlined functions can not see the locals of the caller, or jump out of its loops
+	loops  := c.loops
+	c.loops = nil
+	c.inModule(sym.Owner, func() {
+		c.compileFuncBody(sym.Func)
+	})
+	c.loops = loops
 }
 
-func (c *Compiler) compileFunc(f Func) {
-	if !f.Used {
-		f.Used = true;
-		c.funcs[f.Node.Name.Value] = f
-	}
+func (c *Compiler) compileFunc(sym *Symbol) {
+	sym.compiled = true
+	sym.Addr     = c.a.Label()
 
-	if f.Node.Attrs & node.AttrInline != 0 {
-		c.compileStmts(f.Node.Body)
-	} else {
-		f.Addr = c.a.Label()
-		c.funcs[f.Node.Name.Value] = f
-		c.compileStmts(f.Node.Body)
-		c.a.AddInst("ret")
-	}
+	c.inModule(sym.Owner, func() {
+		c.compileFuncBody(sym.Func)
+	})
+	c.a.AddInst("ret")
 
-	toCompile := make([]Func, len(c.toCompile))
+	c.compileQueued()
+}
+
+func (c *Compiler) compileQueued() {
+	toCompile := make([]*Symbol, len(c.toCompile))
 	copy(toCompile, c.toCompile)
 	c.toCompile = c.toCompile[:0]
 
@@ -217,32 +328,60 @@ func (c *Compiler) compileFunc(f Func) {
 	copy(deferredCalls, c.deferredCalls)
 	c.deferredCalls = c.deferredCalls[:0]
 
-	for _, func_ := range toCompile {
-		if func_ := c.funcs[func_.Node.Name.Value]; func_.Used {
+	for _, sym := range toCompile {
+		if sym.compiled {
 			continue
 		}
 
-		c.compileFunc(func_)
+		c.compileFunc(sym)
 	}
 
 	for _, call := range deferredCalls {
-		c.a.GetInstAt(call.Addr).Data = c.funcs[call.Name].Addr
+		c.a.GetInstAt(call.Addr).Data = call.Func.Addr
 	}
 }
 
 func (c *Compiler) compileStmts(n *node.Stmts) {
+	c.pushScope()
 	for _, stmt := range n.List {
 		c.compileStmt(stmt)
 	}
+	c.popScope()
+}
+
+// Whether every path through the statements ends with a return
+func alwaysReturns(n *node.Stmts) bool {
+	if n == nil || len(n.List) == 0 {
+		return false
+	}
+
+	switch s := n.List[len(n.List) - 1].(type) {
+	case *node.Return: return true
+	case *node.If:     return s.Else != nil && alwaysReturns(s.Then) && alwaysReturns(s.Else)
+
+	case *node.Match:
+		for _, arm := range s.Arms {
+			if !alwaysReturns(arm.Body) {
+				return false
+			}
+		}
+		return s.Else != nil && alwaysReturns(s.Else)
+
+	default: return false
+	}
 }
 
 func (c *Compiler) compileStmt(n node.Stmt) {
 	switch s := n.(type) {
-	case *node.ExprStmt:  c.compileExpr(s.Expr)
+	case *node.ExprStmt:  c.compileExprStmt(s)
 	case *node.Let:       c.compileLet(s)
+	case *node.Macro:
+		c.scope.Symbols[s.Name.Value] = c.newMacro(s)
+
 	case *node.Return:    c.compileReturn(s)
 	case *node.If:        c.compileIf(s)
 	case *node.While:     c.compileWhile(s)
+	case *node.Match:     c.compileMatch(s)
 	case *node.For:       c.compileFor(s)
 	case *node.Assign:    c.compileAssign(s)
 	case *node.Increment: c.compileIncrement(s)
@@ -253,13 +392,29 @@ func (c *Compiler) compileStmt(n node.Stmt) {
 	}
 }
 
+func (c *Compiler) compileExprStmt(n *node.ExprStmt) {
+	c.compileExpr(n.Expr)
+
+	// Discard the value, for example the return value of a function called as a statement
+	for i := 0; i < c.types[n.Expr].Words(); i ++ {
+		c.a.AddInst("pop")
+	}
+}
+
 func (c *Compiler) compileExpr(n node.Expr) {
 	switch e := n.(type) {
-	case *node.Int:      c.compileInt(e)
-	case *node.Bool:     c.compileBool(e)
-	case *node.String:   c.compileString(e)
-	case *node.FuncCall: c.compileFuncCall(e)
-	case *node.Id:       c.compileId(e)
+	case *node.Int:         c.compileInt(e)
+	case *node.Float:       c.compileFloat(e)
+	case *node.Bool:        c.compileBool(e)
+	case *node.Char:        c.compileChar(e)
+	case *node.String:      c.compileString(e)
+	case *node.FuncCall:    c.compileFuncCall(e)
+	case *node.Id:          c.compileId(e)
+	case *node.FieldAccess: c.compileFieldAccess(e)
+	case *node.Array:       c.compileArray(e)
+	case *node.Index:       c.compileIndex(e)
+	case *node.Slice:       c.compileSlice(e)
+	case *node.MacroArg:    c.compileMacroArg(e)
 
 	default: panic("TODO: Unimplemented")
 	}
@@ -269,166 +424,168 @@ func (c *Compiler) compileInt(n *node.Int) {
 	c.a.AddInstWith("psh", agen.Word(n.Value))
 }
 
-func (c *Compiler) compileBool(n *node.Bool) {
-	if n.Value {
-		c.a.AddInstWith("psh", 1)
+func boolToWord(b bool) agen.Word {
+	if b {
+		return 1
 	} else {
-		c.a.AddInstWith("psh", 0)
+		return 0
 	}
 }
 
+func (c *Compiler) compileBool(n *node.Bool) {
+	c.a.AddInstWith("psh", boolToWord(n.Value))
+}
+
+func (c *Compiler) compileChar(n *node.Char) {
+	c.a.AddInstWith("psh", agen.Word(n.Value))
+}
+
 func (c *Compiler) compileString(n *node.String) {
 	addr := c.a.AddMemoryString(n.Value)
 	c.a.AddInstWith("psh", addr)
 	c.a.AddInstWith("psh", agen.Word(len(n.Value)))
 }
 
-func (c *Compiler) getFuncNames() (names []string) {
-	for name, _ := range c.funcs {
-		names = append(names, name)
-	}
-	return
+func (c *Compiler) getFuncNames() []string {
+	return c.scope.Names(func(sym *Symbol) bool {
+		return sym.Kind == SymbolFunc
+	})
 }
 
-func (c *Compiler) getVarAndMacroNames() (names []string) {
-	for name, _ := range c.vars {
-		names = append(names, name)
-	}
-
-	for name, _ := range c.macros {
-		names = append(names, name)
-	}
-	return
+func (c *Compiler) getVarAndMacroNames() []string {
+	return c.scope.Names(func(sym *Symbol) bool {
+		return sym.IsVar() || sym.Kind == SymbolMacro
+	})
 }
 
 func (c *Compiler) compileFuncCall(n *node.FuncCall) {
-	name := n.Name.Value
-
-	for _, expr := range n.Args {
-		c.compileExpr(expr)
+	if _, ok := c.expansions[n]; ok {
+		c.compileMacroCall(n)
+		return
 	}
 
-	// TODO: Make an intrinsic system
-	if name == "writef" {
-		c.a.AddInst("wrf")
-		return
-	} else if name == "iprint" {
-		c.a.AddInst("prt")
-		return
-	} else if name == "fprint" {
-		c.a.AddInst("fpr")
-		return
-	} else if name == "halt" {
-		c.a.AddInst("hlt")
-		return
-	} else if name == "+" {
-		c.a.AddInst("add")
+	if t := castType(n); t != nil {
+		c.compileCast(n, t)
 		return
-	} else if name == "-" {
-		c.a.AddInst("sub")
-		return
-	} else if name == "*" {
-		c.a.AddInst("mul")
-		return
-	} else if name == "/" {
-		c.a.AddInst("div")
-		return
-	} else if name == "%" {
-		c.a.AddInst("mod")
-		return
-	} else if name == "not" {
-		c.a.AddInst("not")
-		return
-	} else if name == "and" {
-		c.a.AddInst("and")
-		return
-	} else if name == "or" {
-		c.a.AddInst("orr")
-		return
-	} else if name == "==" {
-		c.a.AddInst("equ")
-		return
-	} else if name == "/=" {
-		c.a.AddInst("neq")
-		return
-	} else if name == ">" {
-		c.a.AddInst("grt")
-		return
-	} else if name == ">=" {
-		c.a.AddInst("geq")
-		return
-	} else if name == "<" {
-		c.a.AddInst("les")
-		return
-	} else if name == "<=" {
-		c.a.AddInst("leq")
+	} else if intrinsic := c.intrinsic(n); intrinsic != nil {
+		if i, ok := constInt(n); ok {
+			c.a.AddInstWith("psh", agen.Word(i))
+		} else if intrinsic.special != nil {
+			intrinsic.special(c, n)
+		} else {
+			c.compileIntrinsicCall(n, intrinsic)
+		}
 		return
 	}
 
-	func_, ok := c.funcs[name]
-	if !ok {
-		goerror.Error(n.Name.Where, "Unknown function '%v'", name)
+	for _, expr := range n.Args {
+		c.compileExpr(expr)
+	}
 
-		similar := getMostSimilarName(name, c.getFuncNames())
-		if len(similar) > 0 {
-			goerror.NoteSuggestName(n.Name.Where, similar)
-		}
+	// The fields pushed in order are the structure itself
+	sym := c.callee(n)
+	if sym.Kind == SymbolStruct {
 		return
 	}
 
-	if func_.Node.Attrs & node.AttrInline != 0 {
-		c.compileFunc(func_)
+	if sym.Func.Attrs & node.AttrInline != 0 {
+		c.compileInlineFunc(sym)
 	} else {
-		addr := c.a.AddInst("cal")
+		c.compileCall(sym)
+	}
+}
 
-		if !func_.Used {
-			c.toCompile     = append(c.toCompile, func_)
-			c.deferredCalls = append(c.deferredCalls, Call{Name: name, Addr: addr})
-		} else {
-			c.a.GetInstAt(addr).Data = func_.Addr
-		}
+func (c *Compiler) compileCall(sym *Symbol) {
+	addr := c.a.AddInst("cal")
+
+	if !sym.compiled {
+		c.toCompile     = append(c.toCompile, sym)
+		c.deferredCalls = append(c.deferredCalls, Call{Func: sym, Addr: addr})
+	} else {
+		c.a.GetInstAt(addr).Data = sym.Addr
 	}
 }
 
 func (c *Compiler) compileId(n *node.Id) {
-	if macro, ok := c.macros[n.Value]; ok {
-		if !macro.Used {
-			macro.Used = true
-			c.macros[n.Value] = macro
-		}
+	c.compileSymbol(c.scope.Lookup(n.Value))
+}
 
-		c.compileExpr(macro.Expr)
-	} else if var_, ok := c.vars[n.Value]; ok {
-		if !var_.Used {
-			var_.Used = true
-			c.vars[n.Value] = var_
-		}
+// Compiles a read of a variable or an expansion of a macro
+func (c *Compiler) compileSymbol(sym *Symbol) {
+	if sym.Kind == SymbolMacro {
+		c.compileMacro(sym)
+	} else {
+		c.compileReadVar(sym)
+	}
+}
+
+func (c *Compiler) compileMacro(sym *Symbol) {
+	c.expandIn(sym, func() {
+		c.compileExpr(sym.Macro.Expr)
+	})
+}
 
-		c.compileReadVar(n.Value)
+func (c *Compiler) errorUnknownVar(n *node.Id) {
+	goerror.Error(n.Where, "Unknown variable '%v'", n.Value)
+
+	similar := getMostSimilarName(n.Value, c.getVarAndMacroNames())
+	if len(similar) > 0 {
+		goerror.NoteSuggestName(n.Where, similar)
+	}
+}
+
+func (c *Compiler) compileVarAddr(sym *Symbol) {
+	if sym.Kind == SymbolGlobal {
+		c.a.AddInstWith("psh", sym.Addr + agen.Word(sym.Type.ByteOffset()))
+	} else {
+		c.compileLocalAddr(sym)
+		c.compileByteOffset(sym.Type)
 	}
 }
 
-func (c *Compiler) compileReadVar(name string) {
-	c.a.AddInstWith("psh", c.vars[name].Addr)
-	c.a.AddInst(    "r64")
+func (c *Compiler) compileReadVar(sym *Symbol) {
+	c.compileVarAddr(sym)
+	c.compileRead(sym.Type)
 }
 
-func (c *Compiler) compileWriteVar(name string) {
-	c.a.AddInstWith("psh", c.vars[name].Addr)
-	c.a.AddInstWith("swp", 0)
-	c.a.AddInst(    "w64")
+func (c *Compiler) compileWriteVar(sym *Symbol) {
+	c.compileVarAddr(sym)
+	c.compileWrite(sym.Type)
+}
+
+func (c *Compiler) letType(n *node.Let) *value.Type {
+	if n.Type != nil {
+		return n.Type.Type
+	}
+
+	return c.types[n.Expr]
 }
 
 func (c *Compiler) compileLet(n *node.Let) {
-	panic("TODO: Unimplemented")
+	t := c.letType(n)
+	if n.Expr == nil {
+		c.compileVarAddr(c.addLocal(SymbolLocal, n.Name, t))
+		c.compileZero(t)
+		return
+	}
+
+	c.compileExpr(n.Expr)
+
+	// Add the local after compiling the expression, so it cannot refer to itself
+	c.compileWriteVar(c.addLocal(SymbolLocal, n.Name, t))
 }
 
-// TODO: Return does not work properly with inlined functions
 func (c *Compiler) compileReturn(n *node.Return) {
 	if n.Expr != nil {
-		panic("TODO: Unimplemented")
+		c.compileExpr(n.Expr)
+	}
+
+	if c.frame.Func.Attrs & node.AttrInline != 0 {
+		c.frame.returns = append(c.frame.returns, c.a.AddInst("jmp"))
+		return
 	}
 
+	c.compileEpilogue()
 	c.a.AddInst("ret")
 }
 
@@ -442,6 +599,9 @@ func (c *Compiler) compileIf(n *node.If) {
 	*/
 
 	if n.Var != nil {
+		c.pushScope()
+		defer c.popScope()
+
 		c.compileLet(n.Var)                           //     INIT        # let x = 5
 	}
 
@@ -465,28 +625,34 @@ func (c *Compiler) compileIf(n *node.If) {
 	}
 }
 
-func (c *Compiler) startLoop() (isFirst bool) {
-	isFirst = !c.inLoop
-	if isFirst {
-		c.inLoop = true
-	}
-	return
+func (c *Compiler) startLoop(label *node.Id) {
+	c.loops = append(c.loops, &Loop{Label: label})
 }
 
-func (c *Compiler) endLoop(isFirst bool, endLabel agen.Word) {
-	if isFirst {
-		c.inLoop = false
-	}
+// Patches the jumps out of the innermost loop and leaves it
+func (c *Compiler) endLoop(continueLabel, endLabel agen.Word) {
+	loop := c.loops[len(c.loops) - 1]
+	c.loops = c.loops[:len(c.loops) - 1]
 
-	for _, break_ := range c.breaks {
+	for _, break_ := range loop.breaks {
 		c.a.GetInstAt(break_).Data = endLabel
 	}
-	c.breaks = c.breaks[:0]
 
-	for _, continue_ := range c.continues {
-		c.a.GetInstAt(continue_).Data = endLabel
+	for _, continue_ := range loop.continues {
+		c.a.GetInstAt(continue_).Data = continueLabel
 	}
-	c.continues = c.continues[:0]
+}
+
+// Loop a 'break' or 'continue' jumps out of, the checker made sure there is one
+func (c *Compiler) jumpTarget(label *node.Id) *Loop {
+	for i := len(c.loops) - 1; i >= 0; i -- {
+		loop := c.loops[i]
+		if label == nil || (loop.Label != nil && loop.Label.Value == label.Value) {
+			return loop
+		}
+	}
+
+	panic("jump out of no loop")
 }
 
 func (c *Compiler) compileWhile(n *node.While) {
@@ -497,7 +663,7 @@ func (c *Compiler) compileWhile(n *node.While) {
 		}
 	*/
 
-	isFirst := c.startLoop()
+	c.startLoop(n.Label)
 
 	condLabelAddr := c.a.Label()                  // cond:
 	c.compileExpr(n.Cond)                         //     COND       # (< i 10)
@@ -511,7 +677,7 @@ func (c *Compiler) compileWhile(n *node.While) {
 	endLabelAddr := c.a.Label()
 	c.a.GetInstAt(endAddr).Data = endLabelAddr    // end:
 
-	c.endLoop(isFirst, endLabelAddr)
+	c.endLoop(condLabelAddr, endLabelAddr)
 }
 
 func (c *Compiler) compileFor(n *node.For) {
@@ -521,10 +687,13 @@ func (c *Compiler) compileFor(n *node.For) {
 		}
 	*/
 
-	isFirst := c.startLoop()
+	c.startLoop(n.Label)
 
 	if n.Var != nil {
-		c.compileStmt(n.Var)                      //     INIT       # let i = 0
+		c.pushScope()
+		defer c.popScope()
+
+		c.compileLet(n.Var)                       //     INIT       # let i = 0
 	}
 
 	skipAddr := c.a.AddInst("jmp")                //     jmp skip
@@ -539,16 +708,20 @@ func (c *Compiler) compileFor(n *node.For) {
 	endLabelAddr := c.a.Label()
 	c.a.GetInstAt(endAddr).Data = endLabelAddr    // end:
 
-	c.endLoop(isFirst, endLabelAddr)
+	c.endLoop(condLabel, endLabelAddr)
 }
 
 func (c *Compiler) compileAssign(n *node.Assign) {
 	c.compileExpr(n.Expr)
-	c.compileWriteVar(n.Name.Value)
+	c.compileAddr(n.Target)
+	c.compileWrite(c.types[n.Target])
 }
 
 func (c *Compiler) compileIncrement(n *node.Increment) {
-	c.compileReadVar(n.Name.Value)
+	t := c.types[n.Target]
+	c.compileAddr(n.Target)
+	c.a.AddInstWith("dup", 0)
+	c.compileRead(t)
 
 	if n.Negative {
 		c.a.AddInst("dec")
@@ -556,23 +729,16 @@ func (c *Compiler) compileIncrement(n *node.Increment) {
 		c.a.AddInst("inc")
 	}
 
-	c.compileWriteVar(n.Name.Value)
+	c.a.AddInst(writeInsts[t.Width])
 }
 
 func (c *Compiler) compileBreak(n *node.Break) {
-	if !c.inLoop {
-		goerror.Error(n.Where, "'break' outside of a loop")
-		return
-	}
-
-	c.breaks = append(c.breaks, c.a.AddInst("jmp"))
+	loop := c.jumpTarget(n.Label)
+	loop.breaks = append(loop.breaks, c.a.AddInst("jmp"))
 }
 
+// 'continue' jumps to the condition of a 'while' loop, or to the step of a 'for' loop
 func (c *Compiler) compileContinue(n *node.Continue) {
-	if !c.inLoop {
-		goerror.Error(n.Where, "'continue' outside of a loop")
-		return
-	}
-
-	c.continues = append(c.continues, c.a.AddInst("jmp"))
+	loop := c.jumpTarget(n.Label)
+	loop.continues = append(loop.continues, c.a.AddInst("jmp"))
 }
@@ -0,0 +1,143 @@
+package compiler
+
+import (
+	"github.com/avm-collection/goerror"
+	"github.com/avm-collection/agen"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/token"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+/*
+	Enumerations are types of named integer constants. Members without a value take the value of
+	the previous member plus one, the first one is 0:
+
+		enum Color {
+			red         # 0
+			green = 5   # 5
+			blue        # 6
+		}
+
+	Members are accessed through the enumeration, like 'Color.red'. Enumerations do not mix with
+	integers or other enumerations, but can be compared and cast to integers with (int c)
+*/
+
+func (c *Compiler) registerEnum(n *node.Enum) {
+	sym := &Symbol{Kind: SymbolEnum, Name: n.Name.Value, Where: n.Where, Owner: c.module,
+	               Enum: n, Type: value.NewEnum(n.Name.Value)}
+	c.define(sym)
+}
+
+// Evaluates the values of the members
+func (k *checker) resolveEnum(sym *Symbol) {
+	next    := int64(0)
+	defined := make(map[string]*node.Member)
+	for _, member := range sym.Enum.Members {
+		name := member.Name.Value
+		if prev, ok := defined[name]; ok {
+			goerror.Error(member.Where, "Member '%v' redefined", name)
+			goerror.Note(prev.Where, "Previously defined here")
+			continue
+		}
+		defined[name] = member
+
+		if member.Value != nil {
+			v, ok := constInt(member.Value)
+			if !ok {
+				goerror.Error(member.Value.NodeWhere(),
+				              "Value of member '%v' has to be a constant integer", name)
+			}
+
+			next = v
+		}
+
+		sym.Type.AddMember(name, next)
+		next ++
+	}
+}
+
+// Enumeration a member access like 'Color.red' or 'module.Color.red' refers to, nil if the
+// expression is not one
+func (c *Compiler) enumOf(n node.Expr) *Symbol {
+	var sym *Symbol
+	switch e := n.(type) {
+	case *node.Id:          sym = c.scope.Lookup(e.Value)
+	case *node.FieldAccess: sym = c.qualified(e)
+	}
+
+	if sym == nil || sym.Kind != SymbolEnum {
+		return nil
+	}
+	return sym
+}
+
+// Finds the enumeration of a member access, marking it and its module as used
+func (k *checker) enumOf(n node.Expr) *Symbol {
+	sym := k.c.enumOf(n)
+	if sym == nil {
+		return nil
+	}
+
+	sym.Used = true
+	if e, ok := n.(*node.FieldAccess); ok {
+		k.lookup(e.Expr.(*node.Id).Value)
+	}
+	return sym
+}
+
+func (k *checker) checkMember(n *node.FieldAccess, sym *Symbol) *value.Type {
+	if sym.Type.Member(n.Field.Value) != nil {
+		return sym.Type
+	}
+
+	goerror.Error(n.Field.Where, "Enumeration '%v' has no member '%v'", sym.Name, n.Field.Value)
+
+	similar := getMostSimilarName(n.Field.Value, sym.Type.MemberNames())
+	if len(similar) > 0 {
+		goerror.NoteSuggestName(n.Field.Where, similar)
+	}
+	return invalid
+}
+
+func (k *checker) checkNameOf(n *node.FuncCall, args []*value.Type) *value.Type {
+	if !k.checkArgs(n, []*value.Type{invalid}, token.Where{}) {
+		return value.String
+	}
+
+	if args[0] != invalid && args[0].Kind != value.KindEnum {
+		goerror.Error(n.Args[0].NodeWhere(), "Expected an enumeration, got '%v'", args[0])
+	}
+	return value.String
+}
+
+func (c *Compiler) compileMember(n *node.FieldAccess, sym *Symbol) {
+	c.a.AddInstWith("psh", agen.Word(sym.Type.Member(n.Field.Value).Value))
+}
+
+// Compares the value with every member, values of no member have an empty name
+func (c *Compiler) compileNameOf(n *node.FuncCall) {
+	c.compileExpr(n.Args[0])
+
+	ends := []agen.Word{}
+	for _, member := range c.types[n.Args[0]].Members {
+		c.a.AddInstWith("dup", 0)                    //     dup 0
+		c.a.AddInstWith("psh", agen.Word(member.Value))
+		c.a.AddInst(    "neq")                       //     neq
+		next := c.a.AddInst("jnz")                   //     jnz next
+		c.a.AddInst(    "pop")                       //     pop
+		c.a.AddInstWith("psh", c.a.AddMemoryString(member.Name))
+		c.a.AddInstWith("psh", agen.Word(len(member.Name)))
+		ends = append(ends, c.a.AddInst("jmp"))      //     jmp end
+		c.a.GetInstAt(next).Data = c.a.Label()       // next:
+	}
+
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInstWith("psh", 0)                        //     psh 0
+	c.a.AddInstWith("psh", 0)                        //     psh 0        # ""
+
+	end := c.a.Label()                               // end:
+	for _, jump := range ends {
+		c.a.GetInstAt(jump).Data = end
+	}
+}
@@ -0,0 +1,143 @@
+package compiler
+
+import (
+	"math"
+
+	"github.com/avm-collection/agen"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+// Arithmetic and comparison intrinsics called with floats use the float instructions instead
+var floatInsts = map[string]string{
+	"+": "fad",
+	"-": "fsb",
+	"*": "fmu",
+	"/": "fdi",
+
+	"==": "feq",
+	"/=": "fne",
+	">":  "fgr",
+	">=": "fgq",
+	"<":  "fle",
+	"<=": "flq",
+}
+
+func floatWord(f float64) agen.Word {
+	return agen.Word(math.Float64bits(f))
+}
+
+func (c *Compiler) compileFloat(n *node.Float) {
+	c.a.AddInstWith("psh", floatWord(n.Value))
+}
+
+func (c *Compiler) compileFloatOp(n *node.FuncCall) bool {
+	if n.Module != nil || len(n.Args) == 0 || c.types[n.Args[0]] != value.Float {
+		return false
+	}
+
+	inst, ok := floatInsts[n.Name.Value]
+	if ok {
+		c.a.AddInst(inst)
+	}
+	return ok
+}
+
+// The AVM has no conversion instructions, so the integer is built up bit by bit, starting at the
+// highest one
+func (c *Compiler) compileIntToFloat() {
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("psh", 0)                        //     psh 0
+	c.a.AddInst(    "les")                           //     les
+	c.a.AddInstWith("swp", 0)                        //     swp 0        # [neg, n]
+	c.a.AddInstWith("dup", 1)                        //     dup 1
+	c.a.AddInst(    "not")                           //     not
+	positive := c.a.AddInst("jnz")                   //     jnz positive
+	c.a.AddInst(    "neg")                           //     neg
+	c.a.GetInstAt(positive).Data = c.a.Label()       // positive:
+	c.a.AddInstWith("psh", floatWord(0))             //     psh 0.0
+	c.a.AddInstWith("psh", 62)                       //     psh 62       # [neg, n, f, bit]
+	loop := c.a.Label()                              // loop:
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("psh", 0)                        //     psh 0
+	c.a.AddInst(    "les")                           //     les
+	end := c.a.AddInst("jnz")                        //     jnz end
+	c.a.AddInstWith("swp", 0)                        //     swp 0        # [neg, n, bit, f]
+	c.a.AddInstWith("psh", floatWord(2))             //     psh 2.0
+	c.a.AddInst(    "fmu")                           //     fmu
+	c.a.AddInstWith("dup", 2)                        //     dup 2
+	c.a.AddInstWith("dup", 2)                        //     dup 2
+	c.a.AddInst(    "bsr")                           //     bsr
+	c.a.AddInstWith("psh", 1)                        //     psh 1
+	c.a.AddInst(    "ban")                           //     ban          # bit of n
+	c.a.AddInst(    "not")                           //     not
+	skip := c.a.AddInst("jnz")                       //     jnz skip
+	c.a.AddInstWith("psh", floatWord(1))             //     psh 1.0
+	c.a.AddInst(    "fad")                           //     fad
+	c.a.GetInstAt(skip).Data = c.a.Label()           // skip:
+	c.a.AddInstWith("swp", 0)                        //     swp 0
+	c.a.AddInst(    "dec")                           //     dec
+	c.a.AddInstWith("jmp", loop)                     //     jmp loop
+	c.a.GetInstAt(end).Data = c.a.Label()            // end:
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInstWith("swp", 0)                        //     swp 0
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInstWith("swp", 0)                        //     swp 0        # [f, neg]
+	c.a.AddInst(    "not")                           //     not
+	done := c.a.AddInst("jnz")                       //     jnz done
+	c.a.AddInstWith("psh", floatWord(0))             //     psh 0.0
+	c.a.AddInstWith("swp", 0)                        //     swp 0
+	c.a.AddInst(    "fsb")                           //     fsb          # 0.0 - f
+	c.a.GetInstAt(done).Data = c.a.Label()           // done:
+}
+
+// Converts the float to an integer from its bits, rounding towards zero
+func (c *Compiler) compileFloatToInt() {
+	const (
+		mantBits = 52
+		expBias  = 1023
+	)
+
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("psh", mantBits)                 //     psh 52
+	c.a.AddInst(    "bsr")                           //     bsr
+	c.a.AddInstWith("psh", 0x7ff)                    //     psh 0x7ff
+	c.a.AddInst(    "ban")                           //     ban          # [f, exp]
+	c.a.AddInstWith("dup", 1)                        //     dup 1
+	c.a.AddInstWith("psh", 1 << mantBits - 1)        //     psh MANT_MASK
+	c.a.AddInst(    "ban")                           //     ban
+	c.a.AddInstWith("psh", 1 << mantBits)            //     psh 1 << 52
+	c.a.AddInst(    "bor")                           //     bor          # [f, exp, mant]
+	c.a.AddInstWith("swp", 0)                        //     swp 0
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("psh", expBias)                  //     psh 1023
+	c.a.AddInst(    "les")                           //     les
+	zero := c.a.AddInst("jnz")                       //     jnz zero     # |f| < 1
+	c.a.AddInstWith("psh", expBias + mantBits)       //     psh 1075
+	c.a.AddInst(    "sub")                           //     sub          # [f, mant, shift]
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("psh", 0)                        //     psh 0
+	c.a.AddInst(    "les")                           //     les
+	right := c.a.AddInst("jnz")                      //     jnz right
+	c.a.AddInst(    "bsl")                           //     bsl
+	left := c.a.AddInst("jmp")                       //     jmp sign
+	c.a.GetInstAt(right).Data = c.a.Label()          // right:
+	c.a.AddInst(    "neg")                           //     neg
+	c.a.AddInst(    "bsr")                           //     bsr
+	shifted := c.a.AddInst("jmp")                    //     jmp sign
+	c.a.GetInstAt(zero).Data = c.a.Label()           // zero:
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInstWith("psh", 0)                        //     psh 0
+	sign := c.a.Label()                              // sign:
+	c.a.GetInstAt(left).Data    = sign
+	c.a.GetInstAt(shifted).Data = sign
+	c.a.AddInstWith("swp", 0)                        //     swp 0        # [n, f]
+	c.a.AddInstWith("psh", 0)                        //     psh 0
+	c.a.AddInst(    "les")                           //     les          # sign bit of f
+	c.a.AddInst(    "not")                           //     not
+	done := c.a.AddInst("jnz")                       //     jnz done
+	c.a.AddInst(    "neg")                           //     neg
+	c.a.GetInstAt(done).Data = c.a.Label()           // done:
+}
@@ -0,0 +1,135 @@
+package compiler
+
+import (
+	"github.com/avm-collection/agen"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+// Size of the memory region reserved for call frames
+const FrameStackSize = 64 * 1024
+
+/*
+	AVM has no frame pointer register, so call frames live in a stack of our own in memory. Two
+	words in memory hold the frame pointer (fp) and the frame stack pointer (sp). A frame looks
+	like this:
+
+		fp + 0:  fp of the caller
+		fp + 8:  first parameter/local
+		fp + 8 + size of the first: second parameter/local
+		...
+*/
+
+type Frame struct {
+	Func *node.Func
+
+	size      agen.Word
+	sizeAddrs []agen.Word // Instructions pushing the size, patched once the body is done
+
+	// Returns in inlined functions jump to the epilogue instead of returning
+	returns []agen.Word
+}
+
+func (c *Compiler) newFrame(f *node.Func) *Frame {
+	return &Frame{Func: f, size: agen.WordSize}
+}
+
+// Every local gets its own slot in the frame, even if its scope has already ended
+func (c *Compiler) addLocal(kind SymbolKind, name *node.Id, t *value.Type) *Symbol {
+	sym := &Symbol{Kind: kind, Name: name.Value, Where: name.Where, Type: t, Offset: c.frame.size}
+	c.scope.Symbols[name.Value] = sym
+	c.frame.size += agen.Word(t.Size())
+	return sym
+}
+
+func (c *Compiler) compilePrologue(f *node.Func) {
+	c.compileStackCheck()
+
+	c.a.AddInstWith("psh", c.sp)               //     psh SP
+	c.a.AddInst(    "r64")                     //     r64          # new fp = sp
+	c.a.AddInstWith("dup", 0)                  //     dup 0
+	c.a.AddInstWith("psh", c.fp)               //     psh FP
+	c.a.AddInst(    "r64")                     //     r64
+	c.a.AddInst(    "w64")                     //     w64          # [new fp] = fp
+
+	c.a.AddInstWith("psh", c.fp)               //     psh FP
+	c.a.AddInstWith("swp", 0)                  //     swp 0
+	c.a.AddInst(    "w64")                     //     w64          # fp = new fp
+
+	c.a.AddInstWith("psh", c.sp)               //     psh SP
+	c.a.AddInstWith("psh", c.fp)               //     psh FP
+	c.a.AddInst(    "r64")                     //     r64
+	c.compileFrameSize()                       //     psh SIZE
+	c.a.AddInst(    "add")                     //     add
+	c.a.AddInst(    "w64")                     //     w64          # sp = fp + SIZE
+
+	// The arguments were pushed in order, so the last one is on the top of the stack
+	params := make([]*Symbol, len(f.Params))
+	for i, param := range f.Params {
+		params[i] = c.addLocal(SymbolParam, param.Name, param.Type.Type)
+	}
+
+	for i := len(params) - 1; i >= 0; i -- {
+		c.compileWriteVar(params[i])
+	}
+}
+
+func (c *Compiler) compileFrameSize() {
+	c.frame.sizeAddrs = append(c.frame.sizeAddrs, c.a.AddInst("psh"))
+}
+
+// Halts if the frame would not fit into the frame stack, instead of overwriting the memory after it
+func (c *Compiler) compileStackCheck() {
+	c.a.AddInstWith("psh", c.sp)               //     psh SP
+	c.a.AddInst(    "r64")                     //     r64
+	c.compileFrameSize()                       //     psh SIZE
+	c.a.AddInst(    "add")                     //     add
+	c.a.AddInstWith("psh", c.stack + FrameStackSize)
+	c.a.AddInst(    "grt")                     //     grt          # sp + SIZE > stack end
+	c.a.AddInst(    "not")                     //     not
+	fits := c.a.AddInst("jnz")                 //     jnz fits
+	c.callHelper("overflow")                   //     cal overflow
+	c.a.GetInstAt(fits).Data = c.a.Label()     // fits:
+}
+
+func (c *Compiler) compileOverflowHelper() {
+	msg := "Runtime error: Stack overflow\n"
+	c.a.AddInstWith("psh", c.a.AddMemoryString(msg))
+	c.a.AddInstWith("psh", agen.Word(len(msg)))
+	c.a.AddInstWith("psh", 2)                  //     psh STDERR
+	c.a.AddInst(    "wrf")                     //     wrf
+	c.a.AddInstWith("psh", 1)                  //     psh 1
+	c.a.AddInst(    "hlt")                     //     hlt
+}
+
+func (c *Compiler) compileEpilogue() {
+	epilogue := c.a.Label()
+	for _, return_ := range c.frame.returns {
+		c.a.GetInstAt(return_).Data = epilogue
+	}
+
+	c.a.AddInstWith("psh", c.sp)               //     psh SP
+	c.a.AddInstWith("psh", c.fp)               //     psh FP
+	c.a.AddInst(    "r64")                     //     r64
+	c.a.AddInst(    "w64")                     //     w64          # sp = fp
+
+	c.a.AddInstWith("psh", c.fp)               //     psh FP
+	c.a.AddInstWith("psh", c.fp)               //     psh FP
+	c.a.AddInst(    "r64")                     //     r64
+	c.a.AddInst(    "r64")                     //     r64
+	c.a.AddInst(    "w64")                     //     w64          # fp = [fp]
+}
+
+func (c *Compiler) compileLocalAddr(sym *Symbol) {
+	c.a.AddInstWith("psh", c.fp)
+	c.a.AddInst(    "r64")
+	c.a.AddInstWith("psh", sym.Offset)
+	c.a.AddInst(    "add")
+}
+
+func (c *Compiler) compileFrameStack() {
+	c.a.AddInstWith("psh", c.sp)
+	c.a.AddInstWith("psh", c.stack)
+	c.a.AddInst(    "w64")
+}
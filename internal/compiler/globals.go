@@ -0,0 +1,316 @@
+package compiler
+
+import (
+	"github.com/avm-collection/goerror"
+
+	"github.com/LordOfTrident/russel/internal/node"
+)
+
+// Collects the globals an initializer reads, including the ones read by the functions it calls
+type depWalker struct {
+	c *Compiler
+
+	scope  *Scope // Top-level scope of the module being walked
+	deps   []*Symbol
+	seen   map[*Symbol]bool
+	locals map[string]bool
+}
+
+func (c *Compiler) globalDeps(sym *Symbol) []*Symbol {
+	w := &depWalker{
+		c: c,
+
+		scope:  sym.Owner.Scope,
+		seen:   make(map[*Symbol]bool),
+		locals: make(map[string]bool),
+	}
+
+	w.walkExpr(sym.Let.Expr)
+	return w.deps
+}
+
+// Finds a top-level symbol, unless a local of the same name hides it
+func (w *depWalker) global(name string) *Symbol {
+	if w.locals[name] {
+		return nil
+	}
+
+	return w.scope.Symbols[name]
+}
+
+func (w *depWalker) qualified(module *node.Id, name string) *Symbol {
+	sym := w.global(module.Value)
+	if sym == nil || sym.Kind != SymbolModule {
+		return nil
+	}
+
+	return sym.Module.Lookup(name)
+}
+
+// Walks the symbol in the scope of its module. Locals are not visible in other modules
+func (w *depWalker) inModule(m *Module, f func()) {
+	if m.Scope == w.scope {
+		f()
+		return
+	}
+
+	prevScope, prevLocals := w.scope, w.locals
+	w.scope, w.locals = m.Scope, make(map[string]bool)
+
+	f()
+
+	w.scope, w.locals = prevScope, prevLocals
+}
+
+func (w *depWalker) walkSymbol(sym *Symbol) {
+	if sym == nil || w.seen[sym] {
+		return
+	}
+
+	switch sym.Kind {
+	case SymbolGlobal:
+		w.seen[sym] = true
+		w.deps = append(w.deps, sym)
+
+	case SymbolMacro:
+		w.seen[sym] = true
+		w.inModule(sym.Owner, func() {
+			w.walkMacro(sym.Macro)
+		})
+
+	case SymbolFunc:
+		w.seen[sym] = true
+
+		// Locals of the caller are not visible in the called function
+		prev := w.locals
+		w.locals = make(map[string]bool)
+		w.inModule(sym.Owner, func() {
+			for _, param := range sym.Func.Params {
+				w.locals[param.Name.Value] = true
+			}
+
+			w.walkStmts(sym.Func.Body)
+		})
+		w.locals = prev
+	}
+}
+
+func (w *depWalker) walkExpr(n node.Expr) {
+	switch e := n.(type) {
+	case *node.Id: w.walkSymbol(w.global(e.Value))
+
+	case *node.FieldAccess:
+		if id, ok := e.Expr.(*node.Id); ok {
+			if sym := w.qualified(id, e.Field.Value); sym != nil {
+				w.walkSymbol(sym)
+				return
+			}
+		}
+
+		w.walkExpr(e.Expr)
+
+	case *node.Array:
+		for _, elem := range e.Elems {
+			w.walkExpr(elem)
+		}
+
+	case *node.Index:
+		w.walkExpr(e.Expr)
+		w.walkExpr(e.Index)
+
+	case *node.Slice:
+		w.walkExpr(e.Expr)
+		if e.Start != nil {
+			w.walkExpr(e.Start)
+		}
+		if e.End != nil {
+			w.walkExpr(e.End)
+		}
+
+	case *node.FuncCall:
+		// Addresses of globals are known before they are initialized
+		if e.Module == nil && e.Name.Value == "addr" && len(e.Args) == 1 {
+			w.walkIndexes(e.Args[0])
+			return
+		}
+
+		for _, arg := range e.Args {
+			w.walkExpr(arg)
+		}
+
+		if e.Module != nil {
+			w.walkSymbol(w.qualified(e.Module, e.Name.Value))
+		} else {
+			w.walkSymbol(w.global(e.Name.Value))
+		}
+	}
+}
+
+// Parameters of macros hide the top-level symbols of the same name
+func (w *depWalker) walkMacro(n *node.Macro) {
+	prev := w.locals
+	w.locals = make(map[string]bool)
+	for name := range prev {
+		w.locals[name] = true
+	}
+
+	for _, param := range n.Params {
+		w.locals[param.Value] = true
+	}
+
+	w.walkExpr(n.Expr)
+	w.locals = prev
+}
+
+// Assigned variables are not dependencies, but the indexes in the assignment target are read
+func (w *depWalker) walkIndexes(n node.Expr) {
+	switch e := n.(type) {
+	case *node.FieldAccess: w.walkIndexes(e.Expr)
+	case *node.Index:
+		w.walkIndexes(e.Expr)
+		w.walkExpr(e.Index)
+	}
+}
+
+func (w *depWalker) walkStmts(n *node.Stmts) {
+	if n == nil {
+		return
+	}
+
+	for _, stmt := range n.List {
+		w.walkStmt(stmt)
+	}
+}
+
+func (w *depWalker) walkStmt(n node.Stmt) {
+	switch s := n.(type) {
+	case *node.ExprStmt:  w.walkExpr(s.Expr)
+	case *node.Assign:
+		w.walkExpr(s.Expr)
+		w.walkIndexes(s.Target)
+
+	case *node.Increment: w.walkExpr(s.Target)
+	case *node.Let:       w.walkLet(s)
+	case *node.Macro:     w.walkMacro(s)
+
+	case *node.Return:
+		if s.Expr != nil {
+			w.walkExpr(s.Expr)
+		}
+
+	case *node.If:
+		if s.Var != nil {
+			w.walkLet(s.Var)
+		}
+
+		w.walkExpr(s.Cond)
+		w.walkStmts(s.Then)
+		w.walkStmts(s.Else)
+
+	case *node.While:
+		w.walkExpr(s.Cond)
+		w.walkStmts(s.Body)
+
+	case *node.Match:
+		w.walkExpr(s.Expr)
+		for _, arm := range s.Arms {
+			w.walkStmts(arm.Body)
+		}
+		w.walkStmts(s.Else)
+
+	case *node.For:
+		if s.Var != nil {
+			w.walkLet(s.Var)
+		}
+
+		w.walkExpr(s.Cond)
+		w.walkStmt(s.Last)
+		w.walkStmts(s.Body)
+	}
+}
+
+func (w *depWalker) walkLet(n *node.Let) {
+	if n.Expr != nil {
+		w.walkExpr(n.Expr)
+	}
+
+	w.locals[n.Name.Value] = true
+}
+
+// Initializes the globals that could not be stored in memory as constants. A global is initialized
+// only after all of the globals it depends on are
+func (c *Compiler) compileGlobalsInit() {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[*Symbol]int)
+	order := []*Symbol{}
+	path  := []*Symbol{}
+
+	var visit func(sym *Symbol)
+	visit = func(sym *Symbol) {
+		switch state[sym] {
+		case visited: return
+		case visiting:
+			c.errorInitCycle(path, sym)
+			return
+		}
+
+		state[sym] = visiting
+		path = append(path, sym)
+
+		if sym.Let.Expr != nil {
+			for _, dep := range c.globalDeps(sym) {
+				visit(dep)
+			}
+		}
+
+		path = path[:len(path) - 1]
+		state[sym] = visited
+		order = append(order, sym)
+	}
+
+	for _, sym := range c.toInit {
+		visit(sym)
+	}
+
+	if goerror.Happened() {
+		return
+	}
+
+	toInit := make(map[*Symbol]bool)
+	for _, sym := range c.toInit {
+		toInit[sym] = true
+	}
+
+	for _, sym := range order {
+		if !toInit[sym] {
+			continue
+		}
+
+		c.inModule(sym.Owner, func() {
+			c.compileExpr(sym.Let.Expr)
+			c.compileWriteVar(sym)
+		})
+	}
+}
+
+func (c *Compiler) errorInitCycle(path []*Symbol, sym *Symbol) {
+	start := 0
+	for i, dep := range path {
+		if dep == sym {
+			start = i
+			break
+		}
+	}
+
+	cycle := append(path[start:], sym)
+
+	goerror.Error(cycle[0].Where, "Initialization cycle of global variable '%v'", cycle[0].Name)
+	for i := 0; i < len(cycle) - 1; i ++ {
+		goerror.Note(cycle[i].Where, "'%v' depends on '%v'", cycle[i].Name, cycle[i + 1].Name)
+	}
+}
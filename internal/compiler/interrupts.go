@@ -0,0 +1,138 @@
+package compiler
+
+import (
+	"sort"
+
+	"github.com/avm-collection/goerror"
+	"github.com/avm-collection/agen"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/token"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+/*
+	The AVM has no interrupt instructions, so interrupts are raised by the program itself with the
+	'raise' intrinsic. Handlers are bound to the number of an interrupt with an attribute:
+
+		proc (on_tick) [interrupt 1] {
+			...
+		}
+
+		(raise 1) # calls on_tick
+
+	Raising an interrupt calls its handler like a function, so the prologue and epilogue of the
+	handler keep the frame of the interrupted function, even in the middle of an expression.
+	Interrupts not known at compile time are raised through a table of the handlers.
+*/
+
+const MaxInterrupt = 255
+
+func (c *Compiler) registerInterrupt(sym *Symbol) {
+	num := sym.Func.Interrupt
+	if num == nil {
+		return
+	}
+
+	if num.Value < 0 || num.Value > MaxInterrupt {
+		goerror.Error(num.Where, "Interrupt %v is out of the range 0 to %v",
+		              num.Value, MaxInterrupt)
+		return
+	}
+
+	if prev, ok := c.interrupts[num.Value]; ok {
+		goerror.Error(num.Where, "Interrupt %v already has a handler", num.Value)
+		goerror.Note(prev.Where, "Handler '%v' defined here", prev.Name)
+		return
+	}
+
+	c.interrupts[num.Value] = sym
+}
+
+func (k *checker) checkInterruptHead(f *node.Func) {
+	if len(f.Params) > 0 {
+		goerror.Error(f.Params[0].Where, "Interrupt handler '%v' can not take parameters",
+		              f.Name.Value)
+	}
+
+	if f.Type != nil {
+		goerror.Error(f.Type.Where, "Interrupt handler '%v' can not return a value", f.Name.Value)
+	}
+
+	if f.Attrs & node.AttrInline != 0 {
+		goerror.Error(f.Name.Where, "Interrupt handler '%v' can not be inline", f.Name.Value)
+	}
+}
+
+func (k *checker) checkRaise(n *node.FuncCall, args []*value.Type) *value.Type {
+	if !k.checkArgs(n, []*value.Type{value.Int}, token.Where{}) {
+		return value.Void
+	}
+
+	k.errorMismatch(n.Args[0].NodeWhere(), value.Int, args[0])
+	if num, ok := constInt(n.Args[0]); ok && k.c.interrupts[num] == nil {
+		goerror.Error(n.Args[0].NodeWhere(), "Interrupt %v has no handler", num)
+	}
+	return value.Void
+}
+
+func (k *checker) errorInterruptCall(n *node.FuncCall, sym *Symbol) {
+	num := sym.Func.Interrupt
+	goerror.Error(n.Where, "Interrupt handler '%v' can not be called", n.Name.Value)
+	if num != nil {
+		goerror.NoteSuggestNewCode(n.Where, "Suggestion: raise its interrupt",
+		                           []string{"(raise " + num.String() + ")"})
+	}
+}
+
+// Handlers are entry points of the program, so all of them are compiled
+func (c *Compiler) compileInterrupts() {
+	for _, num := range c.interruptNums() {
+		if sym := c.interrupts[num]; !sym.compiled {
+			c.compileFunc(sym)
+		}
+	}
+}
+
+func (c *Compiler) interruptNums() []int64 {
+	nums := []int64{}
+	for num := range c.interrupts {
+		nums = append(nums, num)
+	}
+
+	sort.Slice(nums, func(i, j int) bool {return nums[i] < nums[j]})
+	return nums
+}
+
+func (c *Compiler) compileRaise(n *node.FuncCall) {
+	if num, ok := constInt(n.Args[0]); ok {
+		c.compileCall(c.interrupts[num])
+		return
+	}
+
+	c.compileExpr(n.Args[0])
+	c.callHelper("interrupt")
+}
+
+// Calls the handler of the interrupt on the top of the stack
+func (c *Compiler) compileInterruptHelper() {
+	for _, num := range c.interruptNums() {
+		c.a.AddInstWith("dup", 0)              //     dup 0
+		c.a.AddInstWith("psh", agen.Word(num)) //     psh NUM
+		c.a.AddInst(    "neq")                 //     neq
+		next := c.a.AddInst("jnz")             //     jnz next
+		c.a.AddInst(    "pop")                 //     pop
+		c.a.AddInstWith("cal", c.interrupts[num].Addr)
+		c.a.AddInst(    "ret")                 //     ret
+		c.a.GetInstAt(next).Data = c.a.Label() // next:
+	}
+
+	msg := "Runtime error: Unhandled interrupt\n"
+	c.a.AddInst(    "pop")                     //     pop
+	c.a.AddInstWith("psh", c.a.AddMemoryString(msg))
+	c.a.AddInstWith("psh", agen.Word(len(msg)))
+	c.a.AddInstWith("psh", 2)                  //     psh STDERR
+	c.a.AddInst(    "wrf")                     //     wrf
+	c.a.AddInstWith("psh", 1)                  //     psh 1
+	c.a.AddInst(    "hlt")                     //     hlt
+}
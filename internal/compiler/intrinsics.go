@@ -1,40 +1,240 @@
 package compiler
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/avm-collection/agen"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
 /*
-func (c *Compiler) addIntrinsics() {
-	c.funcs["writef"] = &Intrinsic{body: c.genIntrinsicWrf(), inline: true}
+	Intrinsics are the functions built into the compiler. Most of them compile to a sequence of
+	instructions in place of a call:
+
+		(+ a b) # a, b, add
 
-	c.funcs["+"] = &Intrinsic{body: c.genIntrinsicAdd(), inline: true}
-	c.funcs["-"] = &Intrinsic{body: c.genIntrinsicSub(), inline: true}
-	c.funcs["*"] = &Intrinsic{body: c.genIntrinsicMul(), inline: true}
-	c.funcs["/"] = &Intrinsic{body: c.genIntrinsicDiv(), inline: true}
+	Arithmetic and comparisons also work with floats, pointers and strings. These are checked and
+	compiled by their own code, the registry holds the integer versions
+*/
 
-	c.funcs["print-int"]  = &Intrinsic{body: c.genIntrinsicPrt(), inline: true}
+type Intrinsic struct {
+	Args  []*value.Type // Types of the arguments, 'invalid' accepts any type
+	Ret    *value.Type
+	Insts []string      // Instructions the intrinsic compiles to, after its arguments
+
+	// Variadic intrinsics take more arguments of the type of the last one and fold them from the
+	// left, so (- a b c) is (- (- a b) c)
+	Variadic bool
+
+	compile func(c *Compiler)                   // Compiles intrinsics too long for instructions
+	special func(c *Compiler, n *node.FuncCall) // Compiles intrinsics with unusual arguments
 }
 
-func (c *Compiler) genIntrinsicWrf() (asm string) {
-	asm += c.genInst("psh", "1")
-	asm += c.genInst("wrf", "")
+var intrinsics map[string]*Intrinsic
+
+// The registry refers to functions which compile intrinsics, so it can not be initialized directly
+func init() {
+	intrinsics = map[string]*Intrinsic{
+		"writef": {Args: []*value.Type{value.String, value.Int}, Ret: value.Void,
+		           Insts: []string{"wrf"}},
+		"iprint": {Args: []*value.Type{value.Int},   Ret: value.Void, Insts: []string{"prt"}},
+		"fprint": {Args: []*value.Type{value.Float}, Ret: value.Void, Insts: []string{"fpr"}},
+		"halt":   {Args: []*value.Type{value.Int},   Ret: value.Void, Insts: []string{"hlt"}},
+
+		"+": {Args: []*value.Type{value.Int, value.Int}, Ret: value.Int, Insts: []string{"add"},
+		      Variadic: true},
+		"-": {Args: []*value.Type{value.Int, value.Int}, Ret: value.Int, Insts: []string{"sub"},
+		      Variadic: true},
+		"*": {Args: []*value.Type{value.Int, value.Int}, Ret: value.Int, Insts: []string{"mul"},
+		      Variadic: true},
+		"/": {Args: []*value.Type{value.Int, value.Int}, Ret: value.Int, Insts: []string{"div"},
+		      Variadic: true},
+		"%": {Args: []*value.Type{value.Int, value.Int}, Ret: value.Int, Insts: []string{"mod"}},
+
+		"band": {Args: []*value.Type{value.Int, value.Int}, Ret: value.Int, Insts: []string{"ban"},
+		         Variadic: true},
+		"bor":  {Args: []*value.Type{value.Int, value.Int}, Ret: value.Int, Insts: []string{"bor"},
+		         Variadic: true},
+		"bxor": {Args: []*value.Type{value.Int, value.Int}, Ret: value.Int, Variadic: true,
+		         Insts: []string{"dup 1", "dup 1", "ban", "neg", "dec", "swp 1", "bor", "ban"}},
+		"bnot": {Args: []*value.Type{value.Int}, Ret: value.Int, Insts: []string{"neg", "dec"}},
+		"shl":  {Args: []*value.Type{value.Int, value.Int}, Ret: value.Int, Insts: []string{"bsl"}},
+		"shr":  {Args: []*value.Type{value.Int, value.Int}, Ret: value.Int, Insts: []string{"bsr"}},
+		"sar":  {Args: []*value.Type{value.Int, value.Int}, Ret: value.Int,
+		         compile: (*Compiler).compileShiftRightArith},
 
-	return
+		"itof": {Args: []*value.Type{value.Int},   Ret: value.Float,
+		         compile: (*Compiler).compileIntToFloat},
+		"ftoi": {Args: []*value.Type{value.Float}, Ret: value.Int,
+		         compile: (*Compiler).compileFloatToInt},
+
+		// Pointers and characters are stored as integers already
+		"itop": {Args: []*value.Type{value.Int},  Ret: value.Ptr, Insts: []string{}},
+		"ptoi": {Args: []*value.Type{value.Ptr},  Ret: value.Int, Insts: []string{}},
+		"ord":  {Args: []*value.Type{value.Char}, Ret: value.Int, Insts: []string{}},
+
+		"chr":     {Args: []*value.Type{value.Int}, Ret: value.Char,
+		            Insts: []string{"psh 255", "ban"}},
+		"char_at": {Args: []*value.Type{value.String, value.Int}, Ret: value.Char,
+		            Insts: []string{"swp 0", "pop", "add", "r08"}},
+		"concat":  {Args: []*value.Type{value.Ptr, value.String, value.String}, Ret: value.String,
+		            compile: (*Compiler).compileConcat},
+
+		"addr":  {Args: []*value.Type{invalid},            Ret: value.Ptr,
+		          special: (*Compiler).compileAddrOf},
+		"len":   {Args: []*value.Type{invalid},            Ret: value.Int,
+		          special: (*Compiler).compileLen},
+		"read":  {Args: []*value.Type{value.Ptr},          Ret: invalid,
+		          special: (*Compiler).compileTypedRead},
+		"write": {Args: []*value.Type{value.Ptr, invalid}, Ret: value.Void,
+		          special: (*Compiler).compileTypedWrite},
+		"raise": {Args: []*value.Type{value.Int},          Ret: value.Void,
+		          special: (*Compiler).compileRaise},
+
+		"name_of": {Args: []*value.Type{invalid}, Ret: value.String,
+		            special: (*Compiler).compileNameOf},
+
+		"read8":   {Args: []*value.Type{value.Ptr}, Ret: value.Int, Insts: []string{"r08"}},
+		"read16":  {Args: []*value.Type{value.Ptr}, Ret: value.Int, Insts: []string{"r16"}},
+		"read32":  {Args: []*value.Type{value.Ptr}, Ret: value.Int, Insts: []string{"r32"}},
+		"read64":  {Args: []*value.Type{value.Ptr}, Ret: value.Int, Insts: []string{"r64"}},
+		"write8":  {Args: []*value.Type{value.Ptr, value.Int}, Ret: value.Void,
+		            Insts: []string{"w08"}},
+		"write16": {Args: []*value.Type{value.Ptr, value.Int}, Ret: value.Void,
+		            Insts: []string{"w16"}},
+		"write32": {Args: []*value.Type{value.Ptr, value.Int}, Ret: value.Void,
+		            Insts: []string{"w32"}},
+		"write64": {Args: []*value.Type{value.Ptr, value.Int}, Ret: value.Void,
+		            Insts: []string{"w64"}},
+
+		"not": {Args: []*value.Type{value.Bool}, Ret: value.Bool, Insts: []string{"not"}},
+		"and": {Args: []*value.Type{value.Bool, value.Bool}, Ret: value.Bool, Variadic: true,
+		        special: (*Compiler).compileAnd},
+		"or":  {Args: []*value.Type{value.Bool, value.Bool}, Ret: value.Bool, Variadic: true,
+		        special: (*Compiler).compileOr},
+
+		"==": {Args: []*value.Type{invalid, invalid},     Ret: value.Bool, Insts: []string{"equ"}},
+		"/=": {Args: []*value.Type{invalid, invalid},     Ret: value.Bool, Insts: []string{"neq"}},
+		">":  {Args: []*value.Type{value.Int, value.Int}, Ret: value.Bool, Insts: []string{"grt"}},
+		">=": {Args: []*value.Type{value.Int, value.Int}, Ret: value.Bool, Insts: []string{"geq"}},
+		"<":  {Args: []*value.Type{value.Int, value.Int}, Ret: value.Bool, Insts: []string{"les"}},
+		"<=": {Args: []*value.Type{value.Int, value.Int}, Ret: value.Bool, Insts: []string{"leq"}},
+	}
 }
 
-func (c *Compiler) genIntrinsicAdd() string {
-	return c.genInst("add", "")
+func IntrinsicNames() []string {
+	names := []string{}
+	for name := range intrinsics {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
 }
 
-func (c *Compiler) genIntrinsicSub() string {
-	return c.genInst("sub", "")
+func GetIntrinsic(name string) *Intrinsic {
+	return intrinsics[name]
 }
 
-func (c *Compiler) genIntrinsicMul() string {
-	return c.genInst("mul", "")
+func typeName(t *value.Type) string {
+	if t == invalid {
+		return "any"
+	}
+
+	return t.String()
 }
 
-func (c *Compiler) genIntrinsicDiv() string {
-	return c.genInst("div", "")
+func (in *Intrinsic) ArgType(i int) *value.Type {
+	if i >= len(in.Args) {
+		return in.Args[len(in.Args) - 1]
+	}
+
+	return in.Args[i]
 }
 
-func (c *Compiler) genIntrinsicPrt() string {
-	return c.genInst("prt", "")
+// Signature of the intrinsic in the syntax of a call, like '(+ int int...) -> int'
+func (in *Intrinsic) Signature(name string) string {
+	sig := "(" + name
+	for _, arg := range in.Args {
+		sig += " " + typeName(arg)
+	}
+
+	if in.Variadic {
+		sig += "..."
+	}
+	sig += ")"
+
+	if in.Ret != value.Void {
+		sig += " -> " + typeName(in.Ret)
+	}
+	return sig
+}
+
+// Instructions of the intrinsic as assembly, or a description of how it is compiled
+func (in *Intrinsic) Code() string {
+	if in.special != nil || in.compile != nil {
+		return "(compiled by the compiler)"
+	} else if len(in.Insts) == 0 {
+		return "(no instructions)"
+	}
+
+	return strings.Join(in.Insts, "; ")
+}
+
+// Intrinsic a call refers to, nil for calls of functions
+func (c *Compiler) intrinsic(n *node.FuncCall) *Intrinsic {
+	if n.Module != nil {
+		return nil
+	}
+
+	return intrinsics[n.Name.Value]
+}
+
+// Compiles the arguments of the call with the intrinsic after the first ones, folding them
+func (c *Compiler) compileIntrinsicCall(n *node.FuncCall, in *Intrinsic) {
+	first := len(in.Args)
+	for _, arg := range n.Args[:first] {
+		c.compileExpr(arg)
+	}
+	c.compileOp(n, in)
+
+	for _, arg := range n.Args[first:] {
+		c.compileExpr(arg)
+		c.compileOp(n, in)
+	}
+}
+
+// Intrinsics called with floats, strings or unsigned integers compile to their own instructions
+func (c *Compiler) compileOp(n *node.FuncCall, in *Intrinsic) {
+	if !c.compileFloatOp(n) && !c.compileStringOp(n) && !c.compileIntOp(n) {
+		c.compileIntrinsic(in)
+	}
+
+	c.compileNormalize(c.types[n])
+}
+
+func (c *Compiler) compileIntrinsic(in *Intrinsic) {
+	if in.compile != nil {
+		in.compile(c)
+		return
+	}
+
+	for _, inst := range in.Insts {
+		fields := strings.Fields(inst)
+		if len(fields) == 1 {
+			c.a.AddInst(fields[0])
+			continue
+		}
+
+		data, err := strconv.ParseInt(fields[1], 0, 64)
+		if err != nil {
+			panic(err)
+		}
+
+		c.a.AddInstWith(fields[0], agen.Word(data))
+	}
 }
-*/
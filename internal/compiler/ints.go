@@ -0,0 +1,241 @@
+package compiler
+
+import (
+	"strconv"
+
+	"github.com/avm-collection/goerror"
+	"github.com/avm-collection/agen"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/token"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+/*
+	Besides 'int', there are the sized integers 'i8' to 'i32' and 'u8' to 'u64' ('i64' is 'int').
+	They are read and written with their width, so a '*u8' can walk through bytes, but a variable
+	of a sized integer still takes up a word. Results of arithmetic are truncated to the width:
+
+		let x: u8 = 250
+		(iprint (+ x 10)) # 4
+
+	Integer types do not mix, values are converted with casts like (u8 x). Constant integers take
+	the type they are used as, if they fit into it
+*/
+
+// Names of the instructions reading and writing integers of each width
+var (
+	readInsts  = map[int]string{1: "r08", 2: "r16", 4: "r32", 8: "r64"}
+	writeInsts = map[int]string{1: "w08", 2: "w16", 4: "w32", 8: "w64"}
+)
+
+// Type a call converts its argument to, nil if it is not a cast
+func castType(n *node.FuncCall) *value.Type {
+	if n.Module != nil {
+		return nil
+	}
+
+	if t, ok := value.FromName(n.Name.Value); ok && t.Kind == value.KindInt {
+		return t
+	}
+	return nil
+}
+
+func (k *checker) checkCast(n *node.FuncCall, t *value.Type, args []*value.Type) *value.Type {
+	if !k.checkArgs(n, []*value.Type{invalid}, token.Where{}) || args[0] == invalid {
+		return t
+	}
+
+	if args[0].Kind != value.KindInt && args[0].Kind != value.KindEnum && args[0] != value.Char {
+		goerror.Error(n.Args[0].NodeWhere(), "Can not cast type '%v' to '%v'", args[0], t)
+		goerror.Note(n.Args[0].NodeWhere(),
+		             "Only integers, characters and enumerations can be cast")
+	}
+	return t
+}
+
+// Gives a constant integer the type it is expected to have. Returns the type the expression ends
+// up with
+func (k *checker) constAs(n node.Expr, got, expected *value.Type) *value.Type {
+	if e, ok := n.(*node.Array); ok && got.Kind == value.KindArray &&
+	   expected.Kind == value.KindArray && got.Len == expected.Len {
+		for _, elem := range e.Elems {
+			switch k.constAs(elem, k.c.types[elem], expected.Elem) {
+			case expected.Elem:
+			case invalid:       return invalid
+			default:            return got
+			}
+		}
+
+		k.c.types[n] = expected
+		return expected
+	}
+
+	i, ok := constInt(n)
+	lit, isLit := n.(*node.Int)
+	big := isLit && lit.Unsigned
+	if !ok || (got != value.Int && !big) || expected.Kind != value.KindInt {
+		return got
+	}
+
+	fits, str := expected.InRange(i), strconv.FormatInt(i, 10)
+	if big {
+		fits, str = expected == value.U64, lit.String()
+	}
+
+	if !fits {
+		goerror.Error(n.NodeWhere(), "Constant %v does not fit into type '%v'", str, expected)
+		k.mismatches ++
+		return invalid
+	}
+
+	k.c.types[n] = expected
+	return expected
+}
+
+// Literals too big for an 'int' are 'u64' values
+func literalType(n *node.Int) *value.Type {
+	if n.Unsigned {
+		return value.U64
+	}
+	return value.Int
+}
+
+// Integer arguments of an intrinsic all have the type of the first one which is not a constant,
+// so (+ x 1) is a 'u8' if 'x' is
+func (k *checker) operandType(n *node.FuncCall, in *Intrinsic, args []*value.Type) *value.Type {
+	for i, arg := range args {
+		if _, ok := constInt(n.Args[i]); ok || in.ArgType(i) != value.Int {
+			continue
+		} else if arg.Kind == value.KindInt {
+			return arg
+		}
+	}
+
+	return value.Int
+}
+
+func isInt(t *value.Type) bool {
+	return t.Kind == value.KindInt || t == invalid
+}
+
+// Truncates the integer on the top of the stack to the width of its type, extending the sign
+func (c *Compiler) compileNormalize(t *value.Type) {
+	if !t.Narrow() {
+		return
+	}
+
+	mask := agen.Word(1) << agen.Word(t.Width * 8) - 1
+	if t.Unsigned {
+		c.a.AddInstWith("psh", mask)
+		c.a.AddInst(    "ban")
+		return
+	}
+
+	// ((x + sign) & mask) - sign moves the sign bit to the top
+	sign := agen.Word(1) << agen.Word(t.Width * 8 - 1)
+	c.a.AddInstWith("psh", sign)
+	c.a.AddInst(    "add")
+	c.a.AddInstWith("psh", mask)
+	c.a.AddInst(    "ban")
+	c.a.AddInstWith("psh", sign)
+	c.a.AddInst(    "sub")
+}
+
+// Moves the address of a word on the top of the stack to the bytes of the integer in it
+func (c *Compiler) compileByteOffset(t *value.Type) {
+	if offset := t.ByteOffset(); offset != 0 {
+		c.a.AddInstWith("psh", agen.Word(offset))
+		c.a.AddInst(    "add")
+	}
+}
+
+func (c *Compiler) compileCast(n *node.FuncCall, t *value.Type) {
+	c.compileExpr(n.Args[0])
+	c.compileNormalize(t)
+}
+
+// Ordering and division of 'u64' values which do not fit into an 'int' need their own code,
+// narrower unsigned values are never negative. Narrow signed values are shifted as unsigned
+func (c *Compiler) compileIntOp(n *node.FuncCall) bool {
+	if len(n.Args) == 0 {
+		return false
+	}
+
+	t := c.types[n.Args[0]]
+	if t.Narrow() && !t.Unsigned && n.Name.Value == "shr" {
+		c.a.AddInstWith("swp", 0)
+		c.a.AddInstWith("psh", agen.Word(1) << agen.Word(t.Width * 8) - 1)
+		c.a.AddInst(    "ban")
+		c.a.AddInstWith("swp", 0)
+		c.a.AddInst(    "bsr")
+		return true
+	} else if t != value.U64 {
+		return false
+	}
+
+	switch name := n.Name.Value; name {
+	case "/": c.callHelper("udiv")
+	case "%":
+		c.a.AddInstWith("dup", 1)                    //     dup 1
+		c.a.AddInstWith("dup", 1)                    //     dup 1
+		c.callHelper("udiv")                         //     cal udiv     # [a, b, a / b]
+		c.a.AddInst(    "mul")                       //     mul
+		c.a.AddInst(    "sub")                       //     sub          # a - a / b * b
+
+	default:
+		if !isOrdering(name) {
+			return false
+		}
+
+		c.compileFlipSigns()
+		c.compileIntrinsic(intrinsics[name])
+	}
+	return true
+}
+
+// Flipping the sign bits of two values orders them as unsigned by the signed comparisons
+func (c *Compiler) compileFlipSigns() {
+	c.a.AddInstWith("psh", minInt)                   //     psh MIN
+	c.a.AddInst(    "add")                           //     add
+	c.a.AddInstWith("swp", 0)                        //     swp 0
+	c.a.AddInstWith("psh", minInt)                   //     psh MIN
+	c.a.AddInst(    "add")                           //     add
+	c.a.AddInstWith("swp", 0)                        //     swp 0
+}
+
+const minInt = agen.Word(1) << 63
+
+// Divides [a, b] as unsigned. For b below 2^63 the quotient is (a >> 1) / b * 2, plus one if the
+// remainder is still at least b. Bigger divisors go into a at most once
+func (c *Compiler) compileUdivHelper() {
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("psh", 0)                        //     psh 0
+	c.a.AddInst(    "les")                           //     les
+	big := c.a.AddInst("jnz")                        //     jnz big
+	c.a.AddInstWith("dup", 1)                        //     dup 1
+	c.a.AddInstWith("psh", 1)                        //     psh 1
+	c.a.AddInst(    "bsr")                           //     bsr
+	c.a.AddInstWith("dup", 1)                        //     dup 1
+	c.a.AddInst(    "div")                           //     div
+	c.a.AddInstWith("psh", 1)                        //     psh 1
+	c.a.AddInst(    "bsl")                           //     bsl          # [a, b, q]
+	c.a.AddInstWith("dup", 2)                        //     dup 2
+	c.a.AddInstWith("dup", 1)                        //     dup 1
+	c.a.AddInstWith("dup", 3)                        //     dup 3
+	c.a.AddInst(    "mul")                           //     mul
+	c.a.AddInst(    "sub")                           //     sub          # [a, b, q, a - q * b]
+	c.a.AddInstWith("dup", 2)                        //     dup 2
+	c.compileFlipSigns()                             //     ...
+	c.a.AddInst(    "geq")                           //     geq
+	c.a.AddInst(    "add")                           //     add          # [a, b, q]
+	c.a.AddInstWith("swp", 1)                        //     swp 1
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInst(    "ret")                           //     ret
+
+	c.a.GetInstAt(big).Data = c.a.Label()            // big:
+	c.compileFlipSigns()                             //     ...
+	c.a.AddInst(    "geq")                           //     geq
+	c.a.AddInst(    "ret")                           //     ret
+}
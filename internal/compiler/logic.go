@@ -0,0 +1,44 @@
+package compiler
+
+import (
+	"github.com/avm-collection/agen"
+
+	"github.com/LordOfTrident/russel/internal/node"
+)
+
+/*
+	'and' and 'or' evaluate their operands from the left and stop at the first one deciding the
+	result, so the rest can depend on it:
+
+		if (and (/= p (itop 0)) (> (read p) 0)) ...
+*/
+
+func (c *Compiler) compileAnd(n *node.FuncCall) {
+	c.compileShortCircuit(n, false)
+}
+
+func (c *Compiler) compileOr(n *node.FuncCall) {
+	c.compileShortCircuit(n, true)
+}
+
+// Jumps to the end as soon as an operand is equal to 'decides'
+func (c *Compiler) compileShortCircuit(n *node.FuncCall, decides bool) {
+	jumps := []agen.Word{}
+	for _, arg := range n.Args {
+		c.compileExpr(arg)                           //     ARG
+		if !decides {
+			c.a.AddInst("not")                       //     not          # only for 'and'
+		}
+		jumps = append(jumps, c.a.AddInst("jnz"))    //     jnz decided
+	}
+
+	c.a.AddInstWith("psh", boolToWord(!decides))     //     psh !DECIDES
+	end := c.a.AddInst("jmp")                        //     jmp end
+
+	decided := c.a.Label()                           // decided:
+	for _, jump := range jumps {
+		c.a.GetInstAt(jump).Data = decided
+	}
+	c.a.AddInstWith("psh", boolToWord(decides))      //     psh DECIDES
+	c.a.GetInstAt(end).Data = c.a.Label()            // end:
+}
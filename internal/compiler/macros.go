@@ -0,0 +1,176 @@
+package compiler
+
+import (
+	"github.com/avm-collection/goerror"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/token"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+/*
+	Macros with parameters are expanded by substituting the arguments for the parameters in a copy
+	of the macro expression:
+
+		macro (square x) = (* x x)
+
+		(square (+ a 1)) # expands to (* (+ a 1) (+ a 1))
+
+	The names in the macro expression refer to the symbols where the macro is defined and the names
+	in the arguments to the symbols where it is used, so neither can capture the other. Arguments
+	are evaluated every time their parameter is used.
+*/
+
+// Scope of a macro use, the arguments of the macro are evaluated in it
+type caller struct {
+	scope  *Scope
+	module *Module
+
+	parent *caller
+}
+
+func (k *checker) checkMacroParams(n *node.Macro) {
+	seen := make(map[string]*node.Id)
+	for _, param := range n.Params {
+		if prev, ok := seen[param.Value]; ok {
+			goerror.Error(param.Where, "Parameter '%v' redefined", param.Value)
+			goerror.Note(prev.Where, "Previously defined here")
+		}
+
+		seen[param.Value] = param
+	}
+}
+
+// Copies the expression with the parameters replaced
+func substitute(n node.Expr, args map[string]node.Expr) node.Expr {
+	switch e := n.(type) {
+	case *node.Id:
+		if arg, ok := args[e.Value]; ok {
+			return arg
+		}
+
+		return &node.Id{Where: e.Where, Value: e.Value}
+
+	case *node.FuncCall:
+		call := &node.FuncCall{Where: e.Where, Module: e.Module, Name: e.Name}
+		for _, arg := range e.Args {
+			call.Args = append(call.Args, substitute(arg, args))
+		}
+		return call
+
+	case *node.FieldAccess:
+		return &node.FieldAccess{Where: e.Where, Expr: substitute(e.Expr, args), Field: e.Field}
+
+	case *node.Array:
+		array := &node.Array{Where: e.Where}
+		for _, elem := range e.Elems {
+			array.Elems = append(array.Elems, substitute(elem, args))
+		}
+		return array
+
+	case *node.Index:
+		return &node.Index{Where: e.Where, Expr: substitute(e.Expr, args),
+		                   Index: substitute(e.Index, args)}
+
+	case *node.Slice:
+		slice := &node.Slice{Where: e.Where, Expr: substitute(e.Expr, args)}
+		if e.Start != nil {
+			slice.Start = substitute(e.Start, args)
+		}
+		if e.End != nil {
+			slice.End = substitute(e.End, args)
+		}
+		return slice
+
+	// Literals have no names in them
+	default: return n
+	}
+}
+
+// Expands the macro in the scope it is defined in, remembering the scope of the use for its
+// arguments
+func (c *Compiler) inExpansion(sym *Symbol, f func()) {
+	c.callers = &caller{scope: c.scope, module: c.module, parent: c.callers}
+	c.expandIn(sym, f)
+	c.callers = c.callers.parent
+}
+
+func (c *Compiler) inMacroArg(f func()) {
+	prevScope, prevModule, prevCallers := c.scope, c.module, c.callers
+	c.scope, c.module, c.callers = prevCallers.scope, prevCallers.module, prevCallers.parent
+
+	f()
+
+	c.scope, c.module, c.callers = prevScope, prevModule, prevCallers
+}
+
+func (k *checker) checkMacroCall(n *node.FuncCall, sym *Symbol, args []*value.Type) *value.Type {
+	params := sym.Macro.Params
+	if len(params) == 0 {
+		goerror.Error(n.Where, "Macro '%v' has no parameters, it can not be called", n.Name.Value)
+		goerror.Note(sym.Where, "Defined here")
+		return invalid
+	} else if len(n.Args) != len(params) {
+		goerror.Error(n.Where, "Macro '%v' expects %v arguments, got %v",
+		              n.Name.Value, len(params), len(n.Args))
+		goerror.Note(sym.Where, "Defined here")
+		return invalid
+	} else if sym.expanding {
+		goerror.Error(n.Where, "Macro '%v' expands to itself", n.Name.Value)
+		goerror.Note(sym.Where, "Defined here")
+		return invalid
+	}
+
+	substitutes := make(map[string]node.Expr)
+	for i, param := range params {
+		substitutes[param.Value] = &node.MacroArg{Where: n.Args[i].NodeWhere(), Expr: n.Args[i]}
+	}
+
+	expansion := substitute(sym.Macro.Expr, substitutes)
+	k.c.expansions[n] = expansion
+
+	// Errors in the arguments are reported where they are written already
+	for _, arg := range args {
+		if arg == invalid {
+			return invalid
+		}
+	}
+
+	return k.expand(n.Where, sym, func() (t *value.Type) {
+		k.c.inExpansion(sym, func() {
+			t = k.checkExpr(expansion)
+		})
+		return
+	})
+}
+
+// Errors in a macro expression point to the macro definition, so the use is noted after them
+func (k *checker) expand(where token.Where, sym *Symbol, f func() *value.Type) *value.Type {
+	mismatches := k.mismatches
+
+	sym.expanding = true
+	t := f()
+	sym.expanding = false
+
+	if t == invalid || k.mismatches != mismatches {
+		goerror.Note(where, "In expansion of macro '%v'", sym.Name)
+	}
+	return t
+}
+
+// Arguments are checked before the macro is expanded
+func (k *checker) checkMacroArg(n *node.MacroArg) *value.Type {
+	return k.c.types[n.Expr]
+}
+
+func (c *Compiler) compileMacroCall(n *node.FuncCall) {
+	c.inExpansion(c.callee(n), func() {
+		c.compileExpr(c.expansions[n])
+	})
+}
+
+func (c *Compiler) compileMacroArg(n *node.MacroArg) {
+	c.inMacroArg(func() {
+		c.compileExpr(n.Expr)
+	})
+}
@@ -0,0 +1,345 @@
+package compiler
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/avm-collection/goerror"
+	"github.com/avm-collection/agen"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/token"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+/*
+	Match runs the first arm with the value, or the 'else' arm if none has it:
+
+		match c {
+			'a'..'z' 'A'..'Z' -> (writef "letter\n" 1)
+			'_'               -> (writef "underscore\n" 1)
+			else              -> (writef "other\n" 1)
+		}
+
+	Integers, characters and enumerations can be matched. A match of an enumeration without an
+	'else' arm has to handle all of its members.
+
+	AVM has no indirect jumps, so instead of a jump table, dense values are found by a binary
+	search: the range of the values is split into segments of the same arm, and the value is
+	compared with the start of the middle segment until one segment is left. Sparse values are
+	compared one by one.
+
+	Values are matched by keys which the signed comparisons order correctly. Unsigned values have
+	their sign bits flipped, so values at or above 2^63 come after the others
+*/
+
+// Arms with fewer ranges than this are always compared one by one
+const minSearchRanges = 4
+
+// Keys of the values of a match going to the same arm, -1 for the 'else' arm
+type matchRange struct {
+	lo, hi int64
+	arm    int
+
+	where token.Where
+}
+
+func isMatchable(t *value.Type) bool {
+	return t.Kind == value.KindInt || t.Kind == value.KindEnum || t == value.Char || t == invalid
+}
+
+// Value of a constant a match compares with: an integer, a character or a member of an enumeration
+func (c *Compiler) caseValue(n node.Expr) (int64, bool) {
+	switch e := n.(type) {
+	case *node.Char: return int64(e.Value), true
+
+	case *node.FieldAccess:
+		if sym := c.enumOf(e.Expr); sym != nil {
+			if member := sym.Type.Member(e.Field.Value); member != nil {
+				return member.Value, true
+			}
+		}
+		return 0, false
+
+	default: return constInt(n)
+	}
+}
+
+func matchKey(t *value.Type, v int64) int64 {
+	if t.Unsigned {
+		return v ^ math.MinInt64
+	}
+	return v
+}
+
+// Value with the key, as it is written
+func keyString(t *value.Type, key int64) string {
+	if t.Unsigned {
+		return strconv.FormatUint(uint64(key ^ math.MinInt64), 10)
+	}
+	return strconv.FormatInt(key, 10)
+}
+
+// Ranges of the values of the arms, in the order they are written
+func (c *Compiler) matchRanges(n *node.Match) []matchRange {
+	t      := c.types[n.Expr]
+	ranges := []matchRange{}
+	for i, arm := range n.Arms {
+		for _, case_ := range arm.Cases {
+			lo, _ := c.caseValue(case_.Lo)
+			hi    := lo
+			if case_.Hi != nil {
+				hi, _ = c.caseValue(case_.Hi)
+			}
+
+			where := case_.Lo.NodeWhere()
+			ranges = append(ranges, matchRange{lo: matchKey(t, lo), hi: matchKey(t, hi), arm: i,
+			                                   where: where})
+		}
+	}
+
+	return ranges
+}
+
+func (k *checker) checkMatch(n *node.Match) {
+	t := k.checkExpr(n.Expr)
+	if !isMatchable(t) {
+		goerror.Error(n.Expr.NodeWhere(), "Values of type '%v' can not be matched", t)
+		goerror.Note(n.Expr.NodeWhere(),
+		             "Only integers, characters and enumerations can be matched")
+		t = invalid
+	}
+
+	valid := true
+	for _, arm := range n.Arms {
+		for _, case_ := range arm.Cases {
+			valid = k.checkCase(case_.Lo, t) && valid
+			if case_.Hi != nil {
+				valid = k.checkCase(case_.Hi, t) && valid
+			}
+		}
+
+		k.checkStmts(arm.Body)
+	}
+	k.checkStmts(n.Else)
+
+	if !valid || t == invalid {
+		return
+	}
+
+	ranges := k.c.matchRanges(n)
+	for i, r := range ranges {
+		if r.lo > r.hi {
+			goerror.Error(r.where, "Range %v..%v is empty", keyString(t, r.lo), keyString(t, r.hi))
+			continue
+		}
+
+		for _, prev := range ranges[:i] {
+			if prev.lo <= prev.hi && r.lo <= prev.hi && prev.lo <= r.hi {
+				goerror.Error(r.where, "Values of the arm are already matched")
+				goerror.Note(prev.where, "Matched here")
+				break
+			}
+		}
+	}
+
+	if t.Kind == value.KindEnum && n.Else == nil {
+		k.checkExhaustive(n, t, ranges)
+	}
+}
+
+func (k *checker) checkCase(n node.Expr, t *value.Type) bool {
+	if k.errorMismatchExpr(n, t, k.checkExpr(n)) {
+		return false
+	} else if _, ok := k.c.caseValue(n); !ok {
+		goerror.Error(n.NodeWhere(), "Match values have to be constants")
+		return false
+	}
+
+	return true
+}
+
+func (k *checker) checkExhaustive(n *node.Match, t *value.Type, ranges []matchRange) {
+	missing := []string{}
+	for _, member := range t.Members {
+		handled := false
+		for _, r := range ranges {
+			if member.Value >= r.lo && member.Value <= r.hi {
+				handled = true
+				break
+			}
+		}
+
+		if !handled {
+			missing = append(missing, "'" + member.Name + "'")
+		}
+	}
+
+	if len(missing) > 0 {
+		goerror.Error(n.Where, "Match of enumeration '%v' does not handle %v", t,
+		              strings.Join(missing, ", "))
+		goerror.Note(n.Where, "Add arms for them or an 'else' arm")
+	}
+}
+
+func (c *Compiler) compileMatch(n *node.Match) {
+	c.compileExpr(n.Expr)                            //     EXPR         # stays on the stack
+	if c.types[n.Expr].Unsigned {
+		c.a.AddInstWith("psh", minInt)               //     psh MIN
+		c.a.AddInst(    "add")                       //     add          # flips the sign bit
+	}
+
+	ranges := c.matchRanges(n)
+	sort.Slice(ranges, func(i, j int) bool {return ranges[i].lo < ranges[j].lo})
+
+	// Jumps to the arms, the last one is the 'else' arm
+	jumps := make([][]agen.Word, len(n.Arms) + 1)
+	jump  := func(inst string, arm int) {
+		if arm == -1 {
+			arm = len(n.Arms)
+		}
+
+		jumps[arm] = append(jumps[arm], c.a.AddInst(inst))
+	}
+
+	if isDense(ranges) {
+		c.compileSearch(segments(ranges), jump)
+	} else {
+		c.compileCompares(ranges, jump)
+	}
+
+	ends := []agen.Word{}
+	for i, arm := range n.Arms {
+		c.patchJumps(jumps[i])                       // ARM:
+		c.a.AddInst("pop")                           //     pop
+		c.compileStmts(arm.Body)                     //     BODY
+		ends = append(ends, c.a.AddInst("jmp"))      //     jmp end
+	}
+
+	c.patchJumps(jumps[len(n.Arms)])                 // else:
+	c.a.AddInst("pop")                               //     pop
+	if n.Else != nil {
+		c.compileStmts(n.Else)                       //     ELSE_BODY
+	}
+	c.patchJumps(ends)                               // end:
+}
+
+func (c *Compiler) patchJumps(jumps []agen.Word) {
+	label := c.a.Label()
+	for _, jump := range jumps {
+		c.a.GetInstAt(jump).Data = label
+	}
+}
+
+// Whether the values of sorted ranges cover at least half of the values between them
+func isDense(ranges []matchRange) bool {
+	if len(ranges) < minSearchRanges {
+		return false
+	}
+
+	// Counts of the values can be 2^64, which does not fit into an integer
+	covered := 0.0
+	for _, r := range ranges {
+		covered += float64(uint64(r.hi - r.lo)) + 1
+	}
+
+	span := float64(uint64(ranges[len(ranges) - 1].hi - ranges[0].lo)) + 1
+	return covered * 2 >= span
+}
+
+// Splits the values from the first range to the last one into segments of the same arm, the
+// gaps between the ranges go to the 'else' arm
+func segments(ranges []matchRange) []matchRange {
+	segs := []matchRange{ranges[0]}
+	for _, r := range ranges[1:] {
+		// The ranges do not overlap, so neither of these overflows
+		last := &segs[len(segs) - 1]
+		if last.hi < r.lo - 1 {
+			segs = append(segs, matchRange{lo: last.hi + 1, hi: r.lo - 1, arm: -1})
+		} else if r.arm == last.arm {
+			last.hi = r.hi
+			continue
+		}
+
+		segs = append(segs, r)
+	}
+
+	return segs
+}
+
+// Binary search for the segment with the value. Values outside of the segments go to the 'else'
+// arm first, unless the segments reach the lowest or highest key
+func (c *Compiler) compileSearch(segs []matchRange, jump func(inst string, arm int)) {
+	if lo := segs[0].lo; lo != math.MinInt64 {
+		c.a.AddInstWith("dup", 0)                    //     dup 0
+		c.a.AddInstWith("psh", agen.Word(lo))        //     psh LO
+		c.a.AddInst(    "les")                       //     les
+		jump("jnz", -1)                              //     jnz else
+	}
+
+	if hi := segs[len(segs) - 1].hi; hi != math.MaxInt64 {
+		c.a.AddInstWith("dup", 0)                    //     dup 0
+		c.a.AddInstWith("psh", agen.Word(hi))        //     psh HI
+		c.a.AddInst(    "grt")                       //     grt
+		jump("jnz", -1)                              //     jnz else
+	}
+
+	c.compileSegments(segs, jump)
+}
+
+func (c *Compiler) compileSegments(segs []matchRange, jump func(inst string, arm int)) {
+	if len(segs) == 1 {
+		jump("jmp", segs[0].arm)                     //     jmp ARM
+		return
+	}
+
+	mid := len(segs) / 2
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("psh", agen.Word(segs[mid].lo))  //     psh MID
+	c.a.AddInst(    "les")                           //     les
+	below := c.a.AddInst("jnz")                      //     jnz below
+	c.compileSegments(segs[mid:], jump)
+	c.a.GetInstAt(below).Data = c.a.Label()          // below:
+	c.compileSegments(segs[:mid], jump)
+}
+
+// Compares the value with the ranges one by one. Bounds at the lowest or highest key are not
+// compared, every value is within them
+func (c *Compiler) compileCompares(ranges []matchRange, jump func(inst string, arm int)) {
+	for _, r := range ranges {
+		if r.lo == r.hi {
+			c.a.AddInstWith("dup", 0)                //     dup 0
+			c.a.AddInstWith("psh", agen.Word(r.lo))  //     psh LO
+			c.a.AddInst(    "equ")                   //     equ
+			jump("jnz", r.arm)                       //     jnz ARM
+			continue
+		}
+
+		conds := 0
+		if r.lo != math.MinInt64 {
+			c.a.AddInstWith("dup", 0)                //     dup 0
+			c.a.AddInstWith("psh", agen.Word(r.lo))  //     psh LO
+			c.a.AddInst(    "geq")                   //     geq
+			conds ++
+		}
+
+		if r.hi != math.MaxInt64 {
+			c.a.AddInstWith("dup", agen.Word(conds)) //     dup 0/1
+			c.a.AddInstWith("psh", agen.Word(r.hi))  //     psh HI
+			c.a.AddInst(    "leq")                   //     leq
+			conds ++
+		}
+
+		switch conds {
+		case 0: jump("jmp", r.arm)                   //     jmp ARM
+		case 1: jump("jnz", r.arm)                   //     jnz ARM
+		case 2:
+			c.a.AddInst("and")                       //     and
+			jump("jnz", r.arm)                       //     jnz ARM
+		}
+	}
+
+	jump("jmp", -1)                                  //     jmp else
+}
@@ -0,0 +1,167 @@
+package compiler
+
+import (
+	"github.com/avm-collection/agen"
+
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+// Values bigger than this many words are copied with a loop instead of an instruction sequence
+// for every word
+const unrollLimit = 8
+
+// Removes all words of a value on the stack except 'count' words starting at 'at'
+func (c *Compiler) compileExtract(words, at, count int) {
+	for i := 0; i < words - at - count; i ++ {
+		c.a.AddInst("pop")
+	}
+
+	for i := 0; i < at; i ++ {
+		c.a.AddInstWith("swp", agen.Word(count - 1)) //     swp COUNT-1  # [x, a, b] -> [b, a, x]
+		c.a.AddInst(    "pop")                       //     pop          # [b, a]
+
+		// Rotate the words back into order
+		for j := 0; j < count - 1; j ++ {
+			c.a.AddInstWith("swp", agen.Word(j))     //     swp J        # [b, a] -> [a, b]
+		}
+	}
+}
+
+// Replaces the address on the top of the stack with the value it points to
+func (c *Compiler) compileRead(t *value.Type) {
+	if t.Kind == value.KindInt {
+		c.a.AddInst(readInsts[t.Width])
+		if !t.Unsigned {
+			c.compileNormalize(t)
+		}
+		return
+	} else if t.Words() == 1 {
+		c.a.AddInst("r64")
+		return
+	} else if t.Words() > unrollLimit {
+		c.compileReadLoop(t)
+		return
+	}
+
+	for i := 0; i < t.Words(); i ++ {
+		c.a.AddInstWith("dup", 0)                    //     dup 0
+		c.a.AddInstWith("psh", agen.Word(i * value.WordSize))
+		c.a.AddInst(    "add")                       //     add          # address of word I
+		c.a.AddInst(    "r64")                       //     r64
+		c.a.AddInstWith("swp", 0)                    //     swp 0        # keep the address on top
+	}
+	c.a.AddInst("pop")
+}
+
+func (c *Compiler) compileReadLoop(t *value.Type) {
+	c.a.AddInstWith("psh", 0)                        //     psh 0        # [addr, i]
+	loop := c.a.Label()                              // loop:
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("psh", agen.Word(t.Size()))      //     psh SIZE
+	c.a.AddInst(    "geq")                           //     geq
+	end := c.a.AddInst("jnz")                        //     jnz end
+	c.a.AddInstWith("dup", 1)                        //     dup 1
+	c.a.AddInstWith("dup", 1)                        //     dup 1
+	c.a.AddInst(    "add")                           //     add
+	c.a.AddInst(    "r64")                           //     r64          # [addr, i, word]
+	c.a.AddInstWith("swp", 1)                        //     swp 1
+	c.a.AddInstWith("swp", 0)                        //     swp 0        # [word, addr, i]
+	c.a.AddInstWith("psh", value.WordSize)           //     psh 8
+	c.a.AddInst(    "add")                           //     add
+	c.a.AddInstWith("jmp", loop)                     //     jmp loop
+	c.a.GetInstAt(end).Data = c.a.Label()            // end:
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInst(    "pop")                           //     pop
+}
+
+// Writes the value below the address on the top of the stack into the address
+func (c *Compiler) compileWrite(t *value.Type) {
+	if t.Kind == value.KindInt {
+		c.a.AddInstWith("swp", 0)
+		c.a.AddInst(    writeInsts[t.Width])
+		return
+	} else if t.Words() == 1 {
+		c.a.AddInstWith("swp", 0)
+		c.a.AddInst(    "w64")
+		return
+	} else if t.Words() > unrollLimit {
+		c.compileWriteLoop(t)
+		return
+	}
+
+	// The last word is on the top, so write the words backwards
+	for i := t.Words() - 1; i >= 0; i -- {
+		c.a.AddInstWith("swp", 0)                    //     swp 0
+		c.a.AddInstWith("dup", 1)                    //     dup 1
+		c.a.AddInstWith("psh", agen.Word(i * value.WordSize))
+		c.a.AddInst(    "add")                       //     add          # address of word I
+		c.a.AddInstWith("swp", 0)                    //     swp 0
+		c.a.AddInst(    "w64")                       //     w64
+	}
+	c.a.AddInst("pop")
+}
+
+func (c *Compiler) compileWriteLoop(t *value.Type) {
+	// The last word is on the top, so write the words backwards
+	last := agen.Word(t.Size() - value.WordSize)
+	c.a.AddInstWith("psh", last)                     //     psh SIZE-8   # [word, addr, i]
+	loop := c.a.Label()                              // loop:
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("psh", 0)                        //     psh 0
+	c.a.AddInst(    "les")                           //     les
+	end := c.a.AddInst("jnz")                        //     jnz end
+	c.a.AddInstWith("swp", 1)                        //     swp 1        # [i, addr, word]
+	c.a.AddInstWith("dup", 1)                        //     dup 1
+	c.a.AddInstWith("dup", 3)                        //     dup 3
+	c.a.AddInst(    "add")                           //     add
+	c.a.AddInstWith("swp", 0)                        //     swp 0
+	c.a.AddInst(    "w64")                           //     w64          # [i, addr]
+	c.a.AddInstWith("swp", 0)                        //     swp 0
+	c.a.AddInstWith("psh", value.WordSize)           //     psh 8
+	c.a.AddInst(    "sub")                           //     sub
+	c.a.AddInstWith("jmp", loop)                     //     jmp loop
+	c.a.GetInstAt(end).Data = c.a.Label()            // end:
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInst(    "pop")                           //     pop
+}
+
+// Fills the memory at the address on the top of the stack with zeros
+func (c *Compiler) compileZero(t *value.Type) {
+	if t.Kind == value.KindInt {
+		c.a.AddInstWith("psh", 0)
+		c.a.AddInst(    writeInsts[t.Width])
+		return
+	} else if t.Words() > unrollLimit {
+		c.compileZeroLoop(t)
+		return
+	}
+
+	for i := 0; i < t.Words(); i ++ {
+		c.a.AddInstWith("dup", 0)
+		c.a.AddInstWith("psh", agen.Word(i * value.WordSize))
+		c.a.AddInst(    "add")
+		c.a.AddInstWith("psh", 0)
+		c.a.AddInst(    "w64")
+	}
+	c.a.AddInst("pop")
+}
+
+func (c *Compiler) compileZeroLoop(t *value.Type) {
+	c.a.AddInstWith("psh", 0)                        //     psh 0        # [addr, i]
+	loop := c.a.Label()                              // loop:
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("psh", agen.Word(t.Size()))      //     psh SIZE
+	c.a.AddInst(    "geq")                           //     geq
+	end := c.a.AddInst("jnz")                        //     jnz end
+	c.a.AddInstWith("dup", 1)                        //     dup 1
+	c.a.AddInstWith("dup", 1)                        //     dup 1
+	c.a.AddInst(    "add")                           //     add
+	c.a.AddInstWith("psh", 0)                        //     psh 0
+	c.a.AddInst(    "w64")                           //     w64
+	c.a.AddInstWith("psh", value.WordSize)           //     psh 8
+	c.a.AddInst(    "add")                           //     add
+	c.a.AddInstWith("jmp", loop)                     //     jmp loop
+	c.a.GetInstAt(end).Data = c.a.Label()            // end:
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInst(    "pop")                           //     pop
+}
@@ -0,0 +1,193 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/avm-collection/goerror"
+
+	"github.com/LordOfTrident/russel/internal/std"
+	"github.com/LordOfTrident/russel/internal/parser"
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/token"
+)
+
+const ModuleExt = ".rsl"
+
+// A source file with its own namespace
+type Module struct {
+	Name string
+	Path string
+
+	Program *node.Stmts
+	Scope   *Scope
+
+	// Where the file ends, for suggestions of new code
+	End token.Where
+
+	loading bool
+}
+
+// Parses a module and all the modules it imports. Modules are added to the list after the ones
+// they import, so each module can be checked after its dependencies
+func (c *Compiler) loadModule(p *parser.Parser, path string) *Module {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	m    := &Module{Name: name, Path: path, Scope: NewScope(nil), loading: true}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		c.loaded[abs] = m
+	}
+
+	m.Program = p.Parse()
+	m.End     = p.WhereFileEnd
+
+	for _, stmt := range m.Program.List {
+		switch s := stmt.(type) {
+		case *node.Module: m.Name = s.Name.Value
+		case *node.Import: c.importModule(m, s)
+		}
+	}
+
+	m.loading = false
+	c.modules = append(c.modules, m)
+	return m
+}
+
+func (c *Compiler) importModule(m *Module, n *node.Import) {
+	if n.Path == nil {
+		return
+	}
+
+	path := c.findModule(m.Path, n.Path.Value)
+	if len(path) == 0 {
+		goerror.Error(n.Path.Where, "Module '%v' not found", n.Path.Value)
+		return
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+
+	if dep, ok := c.loaded[abs]; ok {
+		if dep.loading {
+			goerror.Error(n.Where, "Import cycle, module '%v' imports itself", dep.Name)
+			return
+		}
+
+		c.imports[n] = dep
+		return
+	}
+
+	data, err := readModule(path)
+	if err != nil {
+		goerror.Error(n.Path.Where, "Could not read module '%v'", path)
+		return
+	}
+
+	c.imports[n] = c.loadModule(parser.New(string(data), path), path)
+}
+
+// Looks for the module relative to the importing file, then in the search paths and the standard
+// library
+func (c *Compiler) findModule(from, path string) string {
+	if len(filepath.Ext(path)) == 0 {
+		path += ModuleExt
+	}
+
+	dirs := append([]string{filepath.Dir(from)}, c.searchPaths...)
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, path)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+
+	if std.Has(path) {
+		return filepath.Join(std.Dir, path)
+	}
+	return ""
+}
+
+func readModule(path string) ([]byte, error) {
+	if name, err := filepath.Rel(std.Dir, path); err == nil && !strings.HasPrefix(name, "..") {
+		return std.Read(filepath.ToSlash(name))
+	}
+
+	return os.ReadFile(path)
+}
+
+func (c *Compiler) registerImport(n *node.Import) {
+	m, ok := c.imports[n]
+	if !ok {
+		return
+	}
+
+	c.define(&Symbol{Kind: SymbolModule, Name: m.Name, Where: n.Where, Owner: c.module,
+	                 Module: m})
+}
+
+// Finds a symbol of a module. Modules imported by the module are not accessible from outside
+func (m *Module) Lookup(name string) *Symbol {
+	sym, ok := m.Scope.Symbols[name]
+	if !ok || sym.Kind == SymbolModule {
+		return nil
+	}
+
+	return sym
+}
+
+func (m *Module) Names() []string {
+	return m.Scope.Names(func(sym *Symbol) bool {
+		return sym.Kind != SymbolModule
+	})
+}
+
+// Symbol of a qualified name like 'module.name', nil if the expression is a field access
+func (c *Compiler) qualified(n *node.FieldAccess) *Symbol {
+	id, ok := n.Expr.(*node.Id)
+	if !ok {
+		return nil
+	}
+
+	sym := c.scope.Lookup(id.Value)
+	if sym == nil || sym.Kind != SymbolModule {
+		return nil
+	}
+
+	return sym.Module.Lookup(n.Field.Value)
+}
+
+// Symbol a function call refers to
+func (c *Compiler) callee(n *node.FuncCall) *Symbol {
+	if n.Module == nil {
+		return c.scope.Lookup(n.Name.Value)
+	}
+
+	return c.scope.Lookup(n.Module.Value).Module.Lookup(n.Name.Value)
+}
+
+// Runs the function in the scope of a module
+func (c *Compiler) inModule(m *Module, f func()) {
+	prevScope, prevModule := c.scope, c.module
+	c.scope, c.module = m.Scope, m
+
+	f()
+
+	c.scope, c.module = prevScope, prevModule
+}
+
+// Macros are expanded in the scope they are defined in, so they can not see the locals of the
+// function they are used in
+func (c *Compiler) expandIn(sym *Symbol, f func()) {
+	prevScope, prevModule := c.scope, c.module
+	c.scope = sym.Scope
+	if sym.Owner != nil {
+		c.module = sym.Owner
+	}
+
+	f()
+
+	c.scope, c.module = prevScope, prevModule
+}
@@ -0,0 +1,151 @@
+package compiler
+
+import (
+	"github.com/avm-collection/goerror"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/token"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+/*
+	Pointers are byte addresses into the AVM memory. Pointer arithmetic works in bytes, so the
+	next element of an '*int' is at (+ p 8). Fields and elements can be accessed through a pointer
+	directly:
+
+		let p = (addr vec)
+		p.x = 5 # writes into vec.x
+*/
+
+func (k *checker) resolvePointerType(n *node.Type) *value.Type {
+	elem := k.resolveType(n.Elem)
+	switch elem {
+	case invalid: return invalid
+	case value.Void:
+		goerror.Error(n.Where, "Pointers can not point to 'void'")
+		goerror.NoteSuggestName(n.Where, value.Ptr.Name)
+		return invalid
+	}
+
+	return value.PointerTo(elem)
+}
+
+// Raw pointers convert to typed pointers and back, like void pointers in C
+func convertible(a, b *value.Type) bool {
+	return a.Kind == value.KindPointer && b.Kind == value.KindPointer &&
+	       (a == value.Ptr || b == value.Ptr)
+}
+
+// Fields and elements are accessed through pointers as if the pointer was the value
+func pointee(t *value.Type) *value.Type {
+	if t.Kind == value.KindPointer && t != value.Ptr {
+		return t.Elem
+	}
+
+	return t
+}
+
+func (k *checker) checkAddrOf(n *node.FuncCall) *value.Type {
+	if !k.checkArgs(n, []*value.Type{invalid}, token.Where{}) {
+		return invalid
+	}
+
+	arg := n.Args[0]
+	t   := k.checkExpr(arg)
+	if t == invalid {
+		return invalid
+	}
+
+	switch arg.(type) {
+	case *node.Id, *node.FieldAccess, *node.Index, *node.FuncCall, *node.MacroArg:
+		if k.c.isAddressable(arg) {
+			return value.PointerTo(t)
+		}
+	}
+
+	goerror.Error(arg.NodeWhere(), "Can not take the address of '%v'", arg)
+	goerror.Note(arg.NodeWhere(), "Only variables and their fields and elements have an address")
+	return invalid
+}
+
+// Checks the calls which work with pointers of any type, returning false if the call is not one
+func (k *checker) checkPointerCall(n *node.FuncCall, args []*value.Type) (*value.Type, bool) {
+	name := n.Name.Value
+	if name == "read" || name == "write" {
+		return k.checkReadWrite(n, args), true
+	} else if len(args) != 2 || args[0].Kind != value.KindPointer {
+		return nil, false
+	}
+
+	switch name {
+	case "+":
+		k.errorMismatch(n.Args[1].NodeWhere(), value.Int, args[1])
+		return args[0], true
+
+	case "-":
+		// Subtracting pointers gives the distance between them
+		if args[1].Kind == value.KindPointer {
+			return value.Int, true
+		}
+
+		k.errorMismatch(n.Args[1].NodeWhere(), value.Int, args[1])
+		return args[0], true
+	}
+
+	if isOrdering(name) {
+		k.errorMismatch(n.Args[1].NodeWhere(), args[0], args[1])
+		return value.Bool, true
+	}
+	return nil, false
+}
+
+func (k *checker) checkReadWrite(n *node.FuncCall, args []*value.Type) *value.Type {
+	types := []*value.Type{invalid}
+	if n.Name.Value == "write" {
+		types = append(types, invalid)
+	}
+
+	if !k.checkArgs(n, types, token.Where{}) || args[0] == invalid {
+		return invalid
+	} else if args[0].Kind != value.KindPointer {
+		goerror.Error(n.Args[0].NodeWhere(), "Expected a pointer, got '%v'", args[0])
+		return invalid
+	} else if args[0] == value.Ptr {
+		goerror.Error(n.Args[0].NodeWhere(), "Raw pointers point to no type, use the sized " +
+		              "'%v8' to '%v64' intrinsics", n.Name.Value, n.Name.Value)
+		return invalid
+	}
+
+	if n.Name.Value == "read" {
+		return args[0].Elem
+	}
+
+	k.errorMismatchExpr(n.Args[1], args[0].Elem, args[1])
+	return value.Void
+}
+
+// Pushes the address of the value a field access or an index applies to
+func (c *Compiler) compileBaseAddr(n node.Expr) {
+	if c.types[n].Kind == value.KindPointer {
+		c.compileExpr(n)
+	} else {
+		c.compileAddr(n)
+	}
+}
+
+func (c *Compiler) compileAddrOf(n *node.FuncCall) {
+	c.compileAddr(n.Args[0])
+}
+
+func (c *Compiler) compileTypedRead(n *node.FuncCall) {
+	c.compileExpr(n.Args[0])
+	c.compileRead(c.types[n])
+}
+
+// Writes of values bigger than a word need the value below the address, so the value is compiled
+// first
+func (c *Compiler) compileTypedWrite(n *node.FuncCall) {
+	c.compileExpr(n.Args[1])
+	c.compileExpr(n.Args[0])
+	c.compileWrite(c.types[n.Args[1]])
+}
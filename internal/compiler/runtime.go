@@ -0,0 +1,55 @@
+package compiler
+
+import (
+	"sort"
+
+	"github.com/avm-collection/agen"
+)
+
+/*
+	Helpers are subroutines of the generated code which are too big to be repeated at every use.
+	They take their arguments from the stack and leave their results on it, like intrinsics. Only
+	the used helpers are compiled, after the rest of the program.
+*/
+
+var helpers = map[string]func(c *Compiler){
+	"compare":   (*Compiler).compileCompareHelper,
+	"copy":      (*Compiler).compileCopyHelper,
+	"concat":    (*Compiler).compileConcatHelper,
+	"interrupt": (*Compiler).compileInterruptHelper,
+	"overflow":  (*Compiler).compileOverflowHelper,
+	"udiv":      (*Compiler).compileUdivHelper,
+}
+
+func (c *Compiler) callHelper(name string) {
+	c.helperCalls[name] = append(c.helperCalls[name], c.a.AddInst("cal"))
+}
+
+func (c *Compiler) compileHelpers() {
+	compiled := make(map[string]agen.Word)
+
+	// Helpers can call other helpers, so keep going until all calls are patched
+	for len(c.helperCalls) > 0 {
+		names := []string{}
+		for name := range c.helperCalls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		name  := names[0]
+		calls := c.helperCalls[name]
+		delete(c.helperCalls, name)
+
+		addr, ok := compiled[name]
+		if !ok {
+			addr = c.a.Label()
+			compiled[name] = addr
+
+			helpers[name](c)
+		}
+
+		for _, call := range calls {
+			c.a.GetInstAt(call).Data = addr
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package compiler
+
+import (
+	"github.com/avm-collection/goerror"
+	"github.com/avm-collection/agen"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/token"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+type SymbolKind int
+const (
+	SymbolFunc = SymbolKind(iota)
+	SymbolGlobal
+	SymbolLocal
+	SymbolParam
+	SymbolMacro
+	SymbolStruct
+	SymbolEnum
+	SymbolModule
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case SymbolFunc:   return "function"
+	case SymbolGlobal: return "global variable"
+	case SymbolLocal:  return "local variable"
+	case SymbolParam:  return "parameter"
+	case SymbolMacro:  return "macro"
+	case SymbolStruct: return "structure"
+	case SymbolEnum:   return "enumeration"
+	case SymbolModule: return "module"
+
+	default: panic("Unreachable")
+	}
+}
+
+type Symbol struct {
+	Kind  SymbolKind
+	Name  string
+	Where token.Where
+	Type  *value.Type
+
+	Used bool
+
+	Func   *node.Func   // Functions
+	Let    *node.Let    // Global variables
+	Macro  *node.Macro  // Macros
+	Struct *node.Struct // Structures
+	Enum   *node.Enum   // Enumerations
+	Module *Module      // Imported modules
+
+	Owner *Module // Module of top-level symbols
+	Scope *Scope  // Scope macros are defined in, their names refer to the symbols there
+
+	Addr   agen.Word // Address of functions and global variables
+	Offset agen.Word // Offset of locals and parameters in the frame
+
+	compiled  bool
+	expanding bool
+}
+
+func (s *Symbol) IsVar() bool {
+	return s.Kind == SymbolGlobal || s.Kind == SymbolLocal || s.Kind == SymbolParam
+}
+
+type Scope struct {
+	Parent  *Scope
+	Symbols map[string]*Symbol
+}
+
+func NewScope(parent *Scope) *Scope {
+	return &Scope{Parent: parent, Symbols: make(map[string]*Symbol)}
+}
+
+func (s *Scope) Lookup(name string) *Symbol {
+	for ; s != nil; s = s.Parent {
+		if sym, ok := s.Symbols[name]; ok {
+			return sym
+		}
+	}
+
+	return nil
+}
+
+// Names of all the symbols visible from the scope that pass the filter
+func (s *Scope) Names(filter func(*Symbol) bool) (names []string) {
+	for ; s != nil; s = s.Parent {
+		for name, sym := range s.Symbols {
+			if filter(sym) {
+				names = append(names, name)
+			}
+		}
+	}
+	return
+}
+
+func (c *Compiler) pushScope() {
+	c.scope = NewScope(c.scope)
+}
+
+func (c *Compiler) popScope() (scope *Scope) {
+	scope   = c.scope
+	c.scope = c.scope.Parent
+	return
+}
+
+// Reports a redefinition of any kind of symbol in the current scope
+func (c *Compiler) checkNameExists(where token.Where, name string) bool {
+	prev, ok := c.scope.Symbols[name]
+	if !ok {
+		return false
+	}
+
+	goerror.Error(where, "%v '%v' redefined", capitalize(prev.Kind.String()), name)
+	goerror.Note(prev.Where, "Previously defined here")
+	return true
+}
+
+// Defines a symbol in the current scope, reporting redefinitions and shadowing
+func (c *Compiler) define(sym *Symbol) bool {
+	if c.checkNameExists(sym.Where, sym.Name) {
+		return false
+	}
+
+	if prev := c.scope.Parent.Lookup(sym.Name); prev != nil {
+		goerror.Warning(sym.Where, "%v '%v' shadows %v '%v'", capitalize(sym.Kind.String()),
+		                sym.Name, prev.Kind, sym.Name)
+		goerror.Note(prev.Where, "Shadowed %v defined here", prev.Kind)
+	}
+
+	c.scope.Symbols[sym.Name] = sym
+	return true
+}
+
+func (c *Compiler) warnUnused(scope *Scope) {
+	for name, sym := range scope.Symbols {
+		if sym.Used || sym.Kind == SymbolFunc {
+			continue
+		}
+
+		// Top-level symbols of imported modules are there for the modules importing them
+		if sym.Owner != nil && sym.Owner != c.root && sym.Kind != SymbolModule {
+			continue
+		}
+
+		switch sym.Kind {
+		case SymbolMacro:  goerror.Warning(sym.Where, "Unused macro '%v'", name)
+		case SymbolParam:  goerror.Warning(sym.Where, "Unused parameter '%v'", name)
+		case SymbolStruct: goerror.Warning(sym.Where, "Unused structure '%v'", name)
+		case SymbolEnum:   goerror.Warning(sym.Where, "Unused enumeration '%v'", name)
+		case SymbolModule: goerror.Warning(sym.Where, "Unused module '%v'", name)
+
+		default: goerror.Warning(sym.Where, "Unused variable '%v'", name)
+		}
+	}
+}
+
+func capitalize(str string) string {
+	if len(str) == 0 || str[0] < 'a' || str[0] > 'z' {
+		return str
+	}
+
+	return string(str[0] - 'a' + 'A') + str[1:]
+}
@@ -0,0 +1,184 @@
+package compiler
+
+import (
+	"github.com/avm-collection/goerror"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+/*
+	A string is the address of its first byte followed by its length in bytes:
+
+		struct string {
+			addr: ptr
+			len:  int
+		}
+
+	Strings do not own their bytes, so slicing a string just moves the address and the length.
+*/
+
+// Comparisons of the ordering returned by the compare helper with 0
+var stringCompareInsts = map[string]string{
+	"==": "equ",
+	"/=": "neq",
+	">":  "grt",
+	">=": "geq",
+	"<":  "les",
+	"<=": "leq",
+}
+
+func (k *checker) checkSlice(n *node.Slice) *value.Type {
+	base := k.checkExpr(n.Expr)
+	for _, bound := range []node.Expr{n.Start, n.End} {
+		if bound != nil {
+			k.errorMismatch(bound.NodeWhere(), value.Int, k.checkExpr(bound))
+		}
+	}
+
+	if base != value.String && base != invalid {
+		goerror.Error(n.Where, "Only strings can be sliced, got '%v'", base)
+		return invalid
+	}
+	return value.String
+}
+
+func (c *Compiler) compileStringIndex(n *node.Index) {
+	c.compileExpr(n.Expr)
+	c.a.AddInst("pop") // Only the address is needed
+	c.compileExpr(n.Index)
+	c.a.AddInst("add")
+	c.a.AddInst("r08")
+}
+
+func (c *Compiler) compileSlice(n *node.Slice) {
+	c.compileExpr(n.Expr)
+	if n.End != nil {
+		c.a.AddInst("pop")
+		c.compileExpr(n.End)
+	}
+
+	if n.Start == nil {
+		return
+	}
+
+	c.compileExpr(n.Start)                           //     START        # [addr, end, start]
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("swp", 2)                        //     swp 2        # [start, end, start, addr]
+	c.a.AddInst(    "add")                           //     add
+	c.a.AddInstWith("swp", 1)                        //     swp 1        # [addr, end, start]
+	c.a.AddInst(    "sub")                           //     sub          # [addr, end - start]
+}
+
+func (c *Compiler) compileStringLen(n node.Expr) {
+	c.compileExpr(n)
+	c.a.AddInstWith("swp", 0)
+	c.a.AddInst(    "pop")
+}
+
+func (c *Compiler) compileStringOp(n *node.FuncCall) bool {
+	if n.Module != nil || len(n.Args) == 0 || c.types[n.Args[0]] != value.String {
+		return false
+	}
+
+	inst, ok := stringCompareInsts[n.Name.Value]
+	if ok {
+		c.callHelper("compare")
+		c.a.AddInstWith("psh", 0)
+		c.a.AddInst(inst)
+	}
+	return ok
+}
+
+func (c *Compiler) compileConcat() {
+	c.callHelper("concat")
+}
+
+// Compares two strings byte by byte, leaving a negative number if the first one is ordered before
+// the second one, a positive number if after and 0 if they are equal
+func (c *Compiler) compileCompareHelper() {
+	c.a.AddInstWith("psh", 0)                        //     psh 0        # [a, a_len, b, b_len, i]
+	loop := c.a.Label()                              // loop:
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("dup", 4)                        //     dup 4
+	c.a.AddInst(    "geq")                           //     geq
+	endA := c.a.AddInst("jnz")                       //     jnz lengths  # i >= a_len
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("dup", 2)                        //     dup 2
+	c.a.AddInst(    "geq")                           //     geq
+	endB := c.a.AddInst("jnz")                       //     jnz lengths  # i >= b_len
+	c.a.AddInstWith("dup", 4)                        //     dup 4
+	c.a.AddInstWith("dup", 1)                        //     dup 1
+	c.a.AddInst(    "add")                           //     add
+	c.a.AddInst(    "r08")                           //     r08          # a[i]
+	c.a.AddInstWith("dup", 3)                        //     dup 3
+	c.a.AddInstWith("dup", 2)                        //     dup 2
+	c.a.AddInst(    "add")                           //     add
+	c.a.AddInst(    "r08")                           //     r08          # b[i]
+	c.a.AddInst(    "sub")                           //     sub
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	differ := c.a.AddInst("jnz")                     //     jnz differ
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInst(    "inc")                           //     inc
+	c.a.AddInstWith("jmp", loop)                     //     jmp loop
+	c.a.GetInstAt(differ).Data = c.a.Label()         // differ:
+	for i := 0; i < 5; i ++ {
+		c.a.AddInstWith("swp", 0)                    //     swp 0
+		c.a.AddInst(    "pop")                       //     pop          # keep a[i] - b[i]
+	}
+	c.a.AddInst("ret")                               //     ret
+
+	lengths := c.a.Label()                           // lengths:
+	c.a.GetInstAt(endA).Data = lengths
+	c.a.GetInstAt(endB).Data = lengths
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInstWith("swp", 0)                        //     swp 0
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInst(    "sub")                           //     sub          # a_len - b_len
+	c.a.AddInstWith("swp", 0)                        //     swp 0
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInst(    "ret")                           //     ret
+}
+
+// Copies bytes, [dst, src, count] -> [dst + count]
+func (c *Compiler) compileCopyHelper() {
+	loop := c.a.Label()                              // loop:
+	c.a.AddInstWith("dup", 0)                        //     dup 0
+	c.a.AddInstWith("psh", 0)                        //     psh 0
+	c.a.AddInst(    "leq")                           //     leq
+	end := c.a.AddInst("jnz")                        //     jnz end
+	c.a.AddInstWith("dup", 2)                        //     dup 2
+	c.a.AddInstWith("dup", 2)                        //     dup 2
+	c.a.AddInst(    "r08")                           //     r08
+	c.a.AddInst(    "w08")                           //     w08          # [dst] = [src]
+	c.a.AddInst(    "dec")                           //     dec
+	c.a.AddInstWith("swp", 1)                        //     swp 1
+	c.a.AddInst(    "inc")                           //     inc
+	c.a.AddInstWith("swp", 1)                        //     swp 1        # [dst+1, src, count-1]
+	c.a.AddInstWith("swp", 0)                        //     swp 0
+	c.a.AddInst(    "inc")                           //     inc
+	c.a.AddInstWith("swp", 0)                        //     swp 0        # [dst+1, src+1, count-1]
+	c.a.AddInstWith("jmp", loop)                     //     jmp loop
+	c.a.GetInstAt(end).Data = c.a.Label()            // end:
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInst(    "pop")                           //     pop
+	c.a.AddInst(    "ret")                           //     ret
+}
+
+// Copies two strings one after another into a buffer, [buf, a, a_len, b, b_len] -> [buf, len]
+func (c *Compiler) compileConcatHelper() {
+	for i := 0; i < 3; i ++ {
+		c.a.AddInstWith("dup", 4)                    //     dup 4        # [buf, a, a_len]
+	}
+	c.callHelper("copy")                             //     cal copy     # [..., b, b_len, end]
+	c.a.AddInstWith("dup", 2)                        //     dup 2
+	c.a.AddInstWith("dup", 2)                        //     dup 2
+	c.callHelper("copy")                             //     cal copy     # [..., end]
+	for i := 0; i < 4; i ++ {
+		c.a.AddInstWith("swp", 0)                    //     swp 0
+		c.a.AddInst(    "pop")                       //     pop          # [buf, end]
+	}
+	c.a.AddInstWith("dup", 1)                        //     dup 1
+	c.a.AddInst(    "sub")                           //     sub          # [buf, end - buf]
+	c.a.AddInst(    "ret")                           //     ret
+}
@@ -0,0 +1,239 @@
+package compiler
+
+import (
+	"github.com/avm-collection/goerror"
+	"github.com/avm-collection/agen"
+
+	"github.com/LordOfTrident/russel/internal/node"
+	"github.com/LordOfTrident/russel/internal/value"
+)
+
+/*
+	A structure value takes up one stack word for every word of its size, in the order of its
+	fields, so constructing a structure is just pushing its fields. In the memory, fields are
+	stored one after another starting at the address of the structure:
+
+		struct Point {
+			x: int # offset 0
+			y: int # offset 8
+		}
+*/
+
+func (c *Compiler) registerStruct(n *node.Struct) {
+	sym := &Symbol{Kind: SymbolStruct, Name: n.Name.Value, Where: n.Where, Owner: c.module,
+	               Struct: n, Type: value.NewStruct(n.Name.Value)}
+	if c.define(sym) {
+		c.structs[sym.Type] = sym
+	}
+}
+
+// Lays out the fields of a structure, after the structures it contains
+func (k *checker) resolveStruct(sym *Symbol) {
+	if k.typed[sym] {
+		return
+	}
+
+	k.resolving[sym] = true
+
+	defined := make(map[string]*node.Field)
+	for _, field := range sym.Struct.Fields {
+		name := field.Name.Value
+		if prev, ok := defined[name]; ok {
+			goerror.Error(field.Where, "Field '%v' redefined", name)
+			goerror.Note(prev.Where, "Previously defined here")
+		}
+		defined[name] = field
+
+		t := k.resolveType(field.Type)
+		if t == value.Void {
+			goerror.Error(field.Where, "Field '%v' can not be of type 'void'", name)
+			t = invalid
+		}
+
+		// Arrays of structures contain the structure too
+		elem := t
+		for elem.Kind == value.KindArray {
+			elem = elem.Elem
+		}
+
+		if elem.Kind == value.KindStruct {
+			inner := k.c.structs[elem]
+			if k.resolving[inner] {
+				goerror.Error(field.Where, "Structure '%v' contains itself through field '%v'",
+				              inner.Name, name)
+				goerror.Note(inner.Where, "Structure '%v' defined here", inner.Name)
+				t = invalid
+			} else {
+				k.resolveStruct(inner)
+			}
+		}
+
+		// Fields with invalid types are added too, so constructions are still checked correctly
+		sym.Type.AddField(name, t)
+	}
+
+	k.resolving[sym] = false
+	k.typed[sym]     = true
+}
+
+func (k *checker) checkField(n *node.FieldAccess, base *value.Type) *value.Type {
+	base = pointee(base)
+	if base == invalid {
+		return invalid
+	} else if base.Kind != value.KindStruct {
+		goerror.Error(n.Field.Where, "Type '%v' has no fields", base)
+		return invalid
+	}
+
+	field := base.Field(n.Field.Value)
+	if field == nil {
+		goerror.Error(n.Field.Where, "Structure '%v' has no field '%v'", base, n.Field.Value)
+
+		similar := getMostSimilarName(n.Field.Value, base.FieldNames())
+		if len(similar) > 0 {
+			goerror.NoteSuggestName(n.Field.Where, similar)
+		}
+		return invalid
+	}
+
+	return field.Type
+}
+
+func (k *checker) checkConstruct(n *node.FuncCall, sym *Symbol, args []*value.Type) *value.Type {
+	t := sym.Type
+	if len(args) != len(t.Fields) {
+		goerror.Error(n.Where, "Structure '%v' has %v fields, got %v values",
+		              sym.Name, len(t.Fields), len(args))
+		goerror.Note(sym.Where, "Defined here")
+		return t
+	}
+
+	for i, field := range t.Fields {
+		if k.errorMismatchExpr(n.Args[i], field.Type, args[i]) {
+			goerror.Note(sym.Struct.Fields[i].Where, "Field '%v' declared here", field.Name)
+		}
+	}
+	return t
+}
+
+// Whether the expression is stored in the memory, so its address can be taken
+func (c *Compiler) isAddressable(n node.Expr) (addressable bool) {
+	switch e := n.(type) {
+	case *node.Id: return c.isSymbolAddressable(c.scope.Lookup(e.Value))
+
+	case *node.FieldAccess:
+		if sym := c.qualified(e); sym != nil {
+			return c.isSymbolAddressable(sym)
+		}
+
+		return c.types[e.Expr].Kind == value.KindPointer || c.isAddressable(e.Expr)
+
+	case *node.Index:
+		if c.types[e.Expr] == value.String {
+			return false
+		}
+
+		return c.types[e.Expr].Kind == value.KindPointer || c.isAddressable(e.Expr)
+
+	// Macro calls and arguments are addressable if what they expand to is
+	case *node.FuncCall:
+		expansion, ok := c.expansions[e]
+		if ok {
+			c.inExpansion(c.callee(e), func() {
+				addressable = c.isAddressable(expansion)
+			})
+		}
+		return
+
+	case *node.MacroArg:
+		c.inMacroArg(func() {
+			addressable = c.isAddressable(e.Expr)
+		})
+		return
+
+	default: return false
+	}
+}
+
+func (c *Compiler) isSymbolAddressable(sym *Symbol) (addressable bool) {
+	if sym.Kind != SymbolMacro {
+		return sym.IsVar()
+	}
+
+	c.expandIn(sym, func() {
+		addressable = c.isAddressable(sym.Macro.Expr)
+	})
+	return
+}
+
+// Pushes the address of a variable or a field or an element of one
+func (c *Compiler) compileAddr(n node.Expr) {
+	switch e := n.(type) {
+	case *node.Id: c.compileSymbolAddr(c.scope.Lookup(e.Value))
+
+	case *node.FieldAccess:
+		if sym := c.qualified(e); sym != nil {
+			c.compileSymbolAddr(sym)
+			return
+		}
+
+		c.compileBaseAddr(e.Expr)
+
+		offset := pointee(c.types[e.Expr]).Field(e.Field.Value).Offset + c.types[e].ByteOffset()
+		if offset != 0 {
+			c.a.AddInstWith("psh", agen.Word(offset))
+			c.a.AddInst(    "add")
+		}
+
+	case *node.Index:
+		c.compileBaseAddr(e.Expr)
+		c.compileElemOffset(e)
+
+	case *node.FuncCall:
+		c.inExpansion(c.callee(e), func() {
+			c.compileAddr(c.expansions[e])
+		})
+
+	case *node.MacroArg:
+		c.inMacroArg(func() {
+			c.compileAddr(e.Expr)
+		})
+
+	default: panic("Unreachable")
+	}
+}
+
+func (c *Compiler) compileSymbolAddr(sym *Symbol) {
+	if sym.Kind != SymbolMacro {
+		c.compileVarAddr(sym)
+		return
+	}
+
+	c.expandIn(sym, func() {
+		c.compileAddr(sym.Macro.Expr)
+	})
+}
+
+func (c *Compiler) compileFieldAccess(n *node.FieldAccess) {
+	if sym := c.enumOf(n.Expr); sym != nil {
+		c.compileMember(n, sym)
+		return
+	} else if sym := c.qualified(n); sym != nil {
+		c.compileSymbol(sym)
+		return
+	}
+
+	if c.isAddressable(n) {
+		c.compileAddr(n)
+		c.compileRead(c.types[n])
+		return
+	}
+
+	// Temporary structures are on the stack, only keep the words of the field
+	base  := c.types[n.Expr]
+	field := base.Field(n.Field.Value)
+
+	c.compileExpr(n.Expr)
+	c.compileExtract(base.Words(), field.Offset / value.WordSize, field.Type.Words())
+	c.compileNormalize(field.Type)
+}
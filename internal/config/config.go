@@ -5,7 +5,7 @@ const (
 	GithubLink = "https://github.com/avm-collection/russel"
 
 	VersionMajor = 0
-	VersionMinor = 18
+	VersionMinor = 43
 	VersionPatch = 1
 
 	AsciiLogo = ` ____                    _
@@ -23,8 +23,13 @@ var Keywords = map[string]token.Type{
 	"macro":  token.Macro,
 	"let":    token.Let,
 	"proc":   token.Proc,
+	"struct": token.Struct,
+	"enum":   token.Enum,
 	"inline": token.Inline,
 
+	"interrupt": token.Interrupt,
+
+	"match":  token.Match,
 	"if":     token.If,
 	"unless": token.Unless,
 	"else":   token.Else,
@@ -127,9 +132,17 @@ func (l *Lexer) NextToken() (tok token.Token) {
 		case ']': tok = l.lexSimpleSym(token.RSquare)
 
 		case ':': tok = l.lexSimpleSym(token.Colon)
-		case '.': tok = l.lexSimpleSym(token.Dot)
+		case '.':
+			if l.peek() == '.' {
+				l.next()
+				tok = l.lexSimpleSym(token.Range)
+				tok.Data = ".."
+			} else {
+				tok = l.lexSimpleSym(token.Dot)
+			}
 
-		case '"': tok = l.lexString()
+		case '"':  tok = l.lexString()
+		case '\'': tok = l.lexChar()
 
 		default:
 			if isDecDigit(l.ch) {
@@ -162,38 +175,21 @@ func (l *Lexer) NextToken() (tok token.Token) {
 func (l *Lexer) lexString() token.Token {
 	l.next()
 
-	str    := ""
-	escape := false
-
+	str := ""
 	for l.ch != '"' {
 		switch l.ch {
+		case EOF: return token.NewError(l.where, "Unterminated string")
 		case '\\':
-			if escape {
-				str   += string('\\')
-				escape = false
-			} else {
-				escape = true
-			}
+			l.next()
 
-		default:
-			if escape {
-				switch l.ch {
-				case 'e': str += string(27)
-				case 'n': str += string('\n')
-				case 'r': str += string('\r')
-				case 't': str += string('\t')
-				case 'v': str += string('\v')
-				case 'b': str += string('\b')
-				case 'f': str += string('\f')
-
-				default: return token.NewError(l.where, "Unknown escape sequence '\\%v'",
-				                               string(l.ch))
-				}
-
-				escape = false
-			} else {
-				str += string(l.ch)
+			ch, err := l.lexEscape()
+			if err != nil {
+				return *err
 			}
+
+			str += string([]byte{ch})
+
+		default: str += string(l.ch)
 		}
 
 		l.next()
@@ -204,6 +200,77 @@ func (l *Lexer) lexString() token.Token {
 	return token.Token{Type: token.String, Data: str}
 }
 
+func (l *Lexer) lexChar() token.Token {
+	l.next()
+
+	ch := l.ch
+	switch l.ch {
+	case '\'':      return token.NewError(l.where, "Empty character literal")
+	case EOF, '\n': return token.NewError(l.where, "Unterminated character literal")
+	case '\\':
+		l.next()
+
+		var err *token.Token
+		if ch, err = l.lexEscape(); err != nil {
+			return *err
+		}
+	}
+
+	l.next()
+	if l.ch != '\'' {
+		return token.NewError(l.where, "Expected \"'\" to close the character literal")
+	}
+
+	l.next()
+
+	return token.Token{Type: token.Char, Data: string([]byte{ch})}
+}
+
+// Lexes the escape sequence after a backslash, ending on its last character
+func (l *Lexer) lexEscape() (byte, *token.Token) {
+	switch l.ch {
+	case '0':  return '\x00', nil
+	case 'a':  return '\a',   nil
+	case 'e':  return '\x1b', nil
+	case 'n':  return '\n',   nil
+	case 'r':  return '\r',   nil
+	case 't':  return '\t',   nil
+	case 'v':  return '\v',   nil
+	case 'b':  return '\b',   nil
+	case 'f':  return '\f',   nil
+	case '\\': return '\\',   nil
+	case '\'': return '\'',   nil
+	case '"':  return '"',    nil
+
+	case 'x':
+		var ch byte
+		for i := 0; i < 2; i ++ {
+			l.next()
+
+			digit := strings.IndexByte("0123456789abcdef", toLower(l.ch))
+			if digit == -1 {
+				err := token.NewError(l.where, "Expected 2 hexadecimal digits after '\\x'")
+				return 0, &err
+			}
+
+			ch = ch * 16 + byte(digit)
+		}
+		return ch, nil
+
+	default:
+		err := token.NewError(l.where, "Unknown escape sequence '\\%v'", string(l.ch))
+		return 0, &err
+	}
+}
+
+func toLower(ch byte) byte {
+	if ch >= 'A' && ch <= 'Z' {
+		return ch - 'A' + 'a'
+	}
+
+	return ch
+}
+
 func (l *Lexer) lexSimpleSym(type_ token.Type) (tok token.Token) {
 	tok = token.Token{Type: type_, Data: string(l.ch)}
 	l.next()
@@ -249,11 +316,23 @@ func (l *Lexer) lexHex() token.Token {
 	return token.Token{Type: token.Hex, Data: str}
 }
 
+// Decimal numbers with a fraction or an exponent are floats, like '1.5' or '2e-3'
 func (l *Lexer) lexDec() token.Token {
-	str := ""
+	str   := ""
+	type_ := token.Dec
 
-	for !isSeparatorCh(l.ch) {
-		if !isDecDigit(l.ch) {
+	for !isSeparatorCh(l.ch) || (l.ch == '.' && type_ == token.Dec && l.peek() != '.') {
+		if l.ch == '.' {
+			type_ = token.Float
+		} else if (l.ch == 'e' || l.ch == 'E') && !strings.ContainsAny(str, "eE") {
+			type_ = token.Float
+
+			if p := l.peek(); p == '-' || p == '+' {
+				str += string(l.ch)
+
+				l.next()
+			}
+		} else if !isDecDigit(l.ch) {
 			return token.NewError(l.where, "Unexpected character '%v' in decimal number",
 			                      string(l.ch))
 		}
@@ -263,7 +342,7 @@ func (l *Lexer) lexDec() token.Token {
 		l.next()
 	}
 
-	return token.Token{Type: token.Dec, Data: str}
+	return token.Token{Type: type_, Data: str}
 }
 
 func (l *Lexer) lexOct() token.Token {
@@ -21,12 +21,30 @@ func (n *Id) String() string {return n.Value}
 type Int struct {
 	Where token.Where
 
-	Value int64
+	Value    int64
+	Unsigned bool // Too big for an 'int', the value holds the bits of a 'u64'
 }
 
 func (n *Int) exprNode() {}
 func (n *Int) NodeWhere() token.Where {return n.Where}
-func (n *Int) String() string {return strconv.Itoa(int(n.Value))}
+func (n *Int) String() string {
+	if n.Unsigned {
+		return strconv.FormatUint(uint64(n.Value), 10)
+	}
+
+	return strconv.FormatInt(n.Value, 10)
+}
+
+// Float
+type Float struct {
+	Where token.Where
+
+	Value float64
+}
+
+func (n *Float) exprNode() {}
+func (n *Float) NodeWhere() token.Where {return n.Where}
+func (n *Float) String() string {return strconv.FormatFloat(n.Value, 'g', -1, 64)}
 
 // Bool
 type Bool struct {
@@ -45,6 +63,17 @@ func (n *Bool) String() string {
 	}
 }
 
+// Char
+type Char struct {
+	Where token.Where
+
+	Value byte
+}
+
+func (n *Char) exprNode() {}
+func (n *Char) NodeWhere() token.Where {return n.Where}
+func (n *Char) String() string {return strconv.QuoteRune(rune(n.Value))}
+
 // String
 type String struct {
 	Where token.Where
@@ -60,14 +89,19 @@ func (n *String) String() string {return "\"" + n.Value + "\""}
 type FuncCall struct {
 	Where token.Where
 
-	Name *Id
-	Args []Expr
+	Module *Id // Functions from other modules are qualified with the module name
+	Name   *Id
+	Args   []Expr
 }
 
 func (n *FuncCall) exprNode() {}
 func (n *FuncCall) NodeWhere() token.Where {return n.Where}
 func (n *FuncCall) String() (str string) {
-	str = "(" + n.Name.String()
+	str = "("
+	if n.Module != nil {
+		str += n.Module.String() + "."
+	}
+	str += n.Name.String()
 
 	for _, s := range n.Args {
 		str += " " + s.String()
@@ -77,3 +111,87 @@ func (n *FuncCall) String() (str string) {
 
 	return
 }
+
+// Field access
+type FieldAccess struct {
+	Where token.Where
+
+	Expr  Expr
+	Field *Id
+}
+
+func (n *FieldAccess) exprNode() {}
+func (n *FieldAccess) NodeWhere() token.Where {return n.Where}
+func (n *FieldAccess) String() string {return n.Expr.String() + "." + n.Field.String()}
+
+// Array literal
+type Array struct {
+	Where token.Where
+
+	Elems []Expr
+}
+
+func (n *Array) exprNode() {}
+func (n *Array) NodeWhere() token.Where {return n.Where}
+func (n *Array) String() (str string) {
+	str = "["
+
+	for i, s := range n.Elems {
+		if i > 0 {
+			str += " "
+		}
+
+		str += s.String()
+	}
+
+	str += "]"
+	return
+}
+
+// Array indexing
+type Index struct {
+	Where token.Where
+
+	Expr  Expr
+	Index Expr
+}
+
+func (n *Index) exprNode() {}
+func (n *Index) NodeWhere() token.Where {return n.Where}
+func (n *Index) String() string {return n.Expr.String() + "[" + n.Index.String() + "]"}
+
+// String slice
+type Slice struct {
+	Where token.Where
+
+	Expr       Expr
+	Start, End Expr // nil if left out
+}
+
+func (n *Slice) exprNode() {}
+func (n *Slice) NodeWhere() token.Where {return n.Where}
+func (n *Slice) String() (str string) {
+	str = n.Expr.String() + "["
+	if n.Start != nil {
+		str += n.Start.String()
+	}
+
+	str += ":"
+	if n.End != nil {
+		str += n.End.String()
+	}
+
+	str += "]"
+	return
+}
+
+// Argument substituted for a macro parameter, its names refer to the symbols at the macro use
+type MacroArg struct {
+	Where token.Where
+
+	Expr Expr
+}
+
+func (n *MacroArg) exprNode() {}
+func (n *MacroArg) NodeWhere() token.Where {return n.Where}
+func (n *MacroArg) String() string {return n.Expr.String()}
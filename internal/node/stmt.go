@@ -38,19 +38,36 @@ func (n *ExprStmt) String() string {return n.Expr.String()}
 type Type struct {
 	Where token.Where
 
-	Type value.Type
+	Module *Id // Types from other modules are qualified with the module name
+	Name    string
+
+	// Arrays and pointers
+	Elem    *Type
+	Len      int64
+	Pointer  bool
+
+	Type *value.Type // Resolved by the checker
 }
 
-func (n *Type) stmtNode() {}
 func (n *Type) NodeWhere() token.Where {return n.Where}
-func (n *Type) String() string {return string(n.Type)}
+func (n *Type) String() string {
+	if n.Pointer {
+		return "*" + n.Elem.String()
+	} else if n.Elem != nil {
+		return fmt.Sprintf("[%v]%v", n.Len, n.Elem.String())
+	} else if n.Module != nil {
+		return n.Module.String() + "." + n.Name
+	}
+
+	return n.Name
+}
 
 // Variable declaration
 type Let struct {
 	Where token.Where
 
 	Name *Id
-	Type *Id
+	Type *Type
 	Expr  Expr
 }
 
@@ -64,25 +81,25 @@ func (n *Let) String() string {
 	}
 }
 
-// Variable assignment
+// Variable or field assignment
 type Assign struct {
 	Where token.Where
 
-	Name *Id
-	Expr  Expr
+	Target Expr
+	Expr   Expr
 }
 
 func (n *Assign) stmtNode() {}
 func (n *Assign) NodeWhere() token.Where {return n.Where}
 func (n *Assign) String() string {
-	return fmt.Sprintf("%v = %v", n.Name.String(), n.Expr.String())
+	return fmt.Sprintf("%v = %v", n.Target.String(), n.Expr.String())
 }
 
-// Variable increment/decrement
+// Variable or field increment/decrement
 type Increment struct {
 	Where token.Where
 
-	Name    *Id
+	Target   Expr
 	Negative bool
 }
 
@@ -90,9 +107,9 @@ func (n *Increment) stmtNode() {}
 func (n *Increment) NodeWhere() token.Where {return n.Where}
 func (n *Increment) String() string {
 	if n.Negative {
-		return fmt.Sprintf("-- %v", n.Name.String())
+		return fmt.Sprintf("-- %v", n.Target.String())
 	} else {
-		return fmt.Sprintf("++ %v", n.Name.String())
+		return fmt.Sprintf("++ %v", n.Target.String())
 	}
 }
 
@@ -100,14 +117,24 @@ func (n *Increment) String() string {
 type Macro struct {
 	Where token.Where
 
-	Name *Id
-	Expr  Expr
+	Name   *Id
+	Params []*Id // Macros with parameters are used like functions
+	Expr    Expr
 }
 
 func (n *Macro) stmtNode() {}
 func (n *Macro) NodeWhere() token.Where {return n.Where}
 func (n *Macro) String() string {
-	return fmt.Sprintf("macro %v = %v", n.Name.String(), n.Expr.String())
+	if len(n.Params) == 0 {
+		return fmt.Sprintf("macro %v = %v", n.Name.String(), n.Expr.String())
+	}
+
+	head := n.Name.String()
+	for _, param := range n.Params {
+		head += " " + param.String()
+	}
+
+	return fmt.Sprintf("macro (%v) = %v", head, n.Expr.String())
 }
 
 // Return
@@ -155,12 +182,68 @@ func (n *If) String()    (str string) {
 	return
 }
 
+// Values of a match arm, a range like '1..5' or a single value without 'Hi'
+type Case struct {
+	Lo, Hi Expr
+}
+
+func (n *Case) String() string {
+	if n.Hi == nil {
+		return n.Lo.String()
+	}
+
+	return fmt.Sprintf("%v..%v", n.Lo.String(), n.Hi.String())
+}
+
+type Arm struct {
+	Where token.Where
+
+	Cases []*Case
+	Body  *Stmts
+}
+
+func (n *Arm) NodeWhere() token.Where {return n.Where}
+func (n *Arm) String() (str string) {
+	for _, case_ := range n.Cases {
+		str += case_.String() + " "
+	}
+
+	return str + "-> " + n.Body.String()
+}
+
+// Match
+type Match struct {
+	Where token.Where
+
+	Expr Expr
+	Arms []*Arm
+	Else *Stmts
+}
+
+func (n *Match) stmtNode() {}
+func (n *Match) NodeWhere() token.Where {return n.Where}
+func (n *Match) String() (str string) {
+	str = fmt.Sprintf("match %v {\n", n.Expr.String())
+
+	for _, arm := range n.Arms {
+		str += arm.String() + "\n"
+	}
+
+	if n.Else != nil {
+		str += "else -> " + n.Else.String() + "\n"
+	}
+
+	str += "}"
+	return
+}
+
 // While
 type While struct {
 	Where token.Where
 
-	Cond  Expr
-	Body *Stmts
+	Label *Id // nil if the loop is not labeled
+	Cond   Expr
+	Body  *Stmts
 
 	Invert bool
 }
@@ -168,17 +251,18 @@ type While struct {
 func (n *While) stmtNode() {}
 func (n *While) NodeWhere() token.Where {return n.Where}
 func (n *While) String() string {
-	return fmt.Sprintf("while %v %v", n.Cond.String(), n.Body.String())
+	return fmt.Sprintf("%vwhile %v %v", labelString(n.Label), n.Cond.String(), n.Body.String())
 }
 
 // For
 type For struct {
 	Where token.Where
 
-	Var  *Let
-	Cond  Expr
-	Last  Stmt
-	Body *Stmts
+	Label *Id // nil if the loop is not labeled
+	Var   *Let
+	Cond   Expr
+	Last   Stmt
+	Body  *Stmts
 
 	Invert bool
 }
@@ -186,7 +270,7 @@ type For struct {
 func (n *For) stmtNode() {}
 func (n *For) NodeWhere() token.Where {return n.Where}
 func (n *For) String() (str string) {
-	str += "for "
+	str += labelString(n.Label) + "for "
 
 	if n.Var != nil {
 		str += n.Var.String() + "; "
@@ -196,46 +280,185 @@ func (n *For) String() (str string) {
 	return
 }
 
+func labelString(label *Id) string {
+	if label == nil {
+		return ""
+	}
+
+	return label.Value + ": "
+}
+
 // Break
 type Break struct {
 	Where token.Where
+
+	Label *Id // nil for the innermost loop
 }
 
 func (n *Break) stmtNode() {}
 func (n *Break) NodeWhere() token.Where {return n.Where}
-func (n *Break) String() string {return "break"}
+func (n *Break) String() string {
+	if n.Label == nil {
+		return "break"
+	}
+
+	return "break " + n.Label.Value
+}
 
 // Continue
 type Continue struct {
 	Where token.Where
+
+	Label *Id // nil for the innermost loop
 }
 
 func (n *Continue) stmtNode() {}
 func (n *Continue) NodeWhere() token.Where {return n.Where}
-func (n *Continue) String() string {return "continue"}
+func (n *Continue) String() string {
+	if n.Label == nil {
+		return "continue"
+	}
+
+	return "continue " + n.Label.Value
+}
 
 const (
 	AttrInline = 1 << iota
 	AttrInterrupt
 )
 
+// Func parameter
+type Param struct {
+	Where token.Where
+
+	Name *Id
+	Type *Type
+}
+
+func (n *Param) NodeWhere() token.Where {return n.Where}
+func (n *Param) String() string {
+	return fmt.Sprintf("%v: %v", n.Name.String(), n.Type.String())
+}
+
 // Func declaration
 type Func struct {
 	Where token.Where
 
-	Attrs int
+	Attrs     int
+	Interrupt *Int // Number of the interrupt handled by interrupt handlers
 
-	Name *Id
-	Type *Id
-	Body *Stmts
+	Name   *Id
+	Params []*Param
+	Type   *Type
+	Body   *Stmts
 }
 
 func (n *Func) stmtNode() {}
 func (n *Func) NodeWhere() token.Where {return n.Where}
 func (n *Func) String() string {
+	params := ""
+	for _, param := range n.Params {
+		params += " " + param.String()
+	}
+
 	if n.Type != nil {
-		return fmt.Sprintf("%v %v %v", n.Type.String(), n.Name.String(), n.Body.String())
+		return fmt.Sprintf("%v %v%v %v", n.Type.String(), n.Name.String(), params,
+		                   n.Body.String())
 	} else {
-		return fmt.Sprintf("void %v %v", n.Name.String(), n.Body.String())
+		return fmt.Sprintf("void %v%v %v", n.Name.String(), params, n.Body.String())
+	}
+}
+
+// Structure field
+type Field struct {
+	Where token.Where
+
+	Name *Id
+	Type *Type
+}
+
+func (n *Field) NodeWhere() token.Where {return n.Where}
+func (n *Field) String() string {
+	return fmt.Sprintf("%v: %v", n.Name.String(), n.Type.String())
+}
+
+// Structure declaration
+type Struct struct {
+	Where token.Where
+
+	Name   *Id
+	Fields []*Field
+}
+
+func (n *Struct) stmtNode() {}
+func (n *Struct) NodeWhere() token.Where {return n.Where}
+func (n *Struct) String() (str string) {
+	str = fmt.Sprintf("struct %v {\n", n.Name.String())
+
+	for _, field := range n.Fields {
+		str += field.String() + "\n"
+	}
+
+	str += "}"
+	return
+}
+
+// Enumeration member, without a value it is the value of the previous member plus one
+type Member struct {
+	Where token.Where
+
+	Name  *Id
+	Value Expr
+}
+
+func (n *Member) NodeWhere() token.Where {return n.Where}
+func (n *Member) String() string {
+	if n.Value == nil {
+		return n.Name.String()
 	}
+
+	return fmt.Sprintf("%v = %v", n.Name.String(), n.Value.String())
 }
+
+// Enumeration declaration
+type Enum struct {
+	Where token.Where
+
+	Name    *Id
+	Members []*Member
+}
+
+func (n *Enum) stmtNode() {}
+func (n *Enum) NodeWhere() token.Where {return n.Where}
+func (n *Enum) String() (str string) {
+	str = fmt.Sprintf("enum %v {\n", n.Name.String())
+
+	for _, member := range n.Members {
+		str += member.String() + "\n"
+	}
+
+	str += "}"
+	return
+}
+
+// Module header
+type Module struct {
+	Where token.Where
+
+	Name *Id
+}
+
+func (n *Module) stmtNode() {}
+func (n *Module) NodeWhere() token.Where {return n.Where}
+func (n *Module) String() string {return "module " + n.Name.String()}
+
+// Import
+type Import struct {
+	Where token.Where
+
+	Path *String
+}
+
+func (n *Import) stmtNode() {}
+func (n *Import) NodeWhere() token.Where {return n.Where}
+func (n *Import) String() string {return "import " + n.Path.String()}
@@ -3,6 +3,7 @@ package parser
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/avm-collection/goerror"
 
@@ -14,7 +15,7 @@ import (
 type Parser struct {
 	WhereFileEnd token.Where
 
-	tok token.Token
+	tok, prev token.Token
 
 	l *lexer.Lexer
 }
@@ -35,9 +36,19 @@ func (p *Parser) Parse() *node.Stmts {
 		var s node.Stmt
 
 		switch p.tok.Type {
-		case token.Proc:  s = p.parseFunc()
-		case token.Let:   s = p.parseLet()
-		case token.Macro: s = p.parseMacro()
+		case token.Proc:   s = p.parseFunc()
+		case token.Let:    s = p.parseLet()
+		case token.Macro:  s = p.parseMacro()
+		case token.Struct: s = p.parseStruct()
+		case token.Enum:   s = p.parseEnum()
+		case token.Import: s = p.parseImport()
+
+		case token.Module:
+			if len(topLevel.List) > 0 {
+				goerror.Error(p.tok.Where, "Module header has to be at the start of the file")
+			}
+
+			s = p.parseModule()
 
 		default:
 			goerror.Error(p.tok.Where, "Unexpected %v in top-level", p.tok)
@@ -91,31 +102,40 @@ func (p *Parser) parseStmt() node.Stmt {
 	case token.While:    return p.parseWhile(false)
 	case token.Until:    return p.parseWhile(true)
 	case token.For:      return p.parseFor()
+	case token.Match:    return p.parseMatch()
 
 	case token.Break:
 		p.next()
-		return &node.Break{Where: tok.Where}
+		return &node.Break{Where: tok.Where, Label: p.parseJumpLabel(tok)}
 
 	case token.Continue:
 		p.next()
-		return &node.Continue{Where: tok.Where}
+		return &node.Continue{Where: tok.Where, Label: p.parseJumpLabel(tok)}
 
 	case token.Increment:
 		p.next()
-		return &node.Increment{Where: tok.Where, Name: p.parseId()}
+		return &node.Increment{Where: tok.Where, Target: p.parseTarget()}
 
 	case token.Decrement:
 		p.next()
-		return &node.Increment{Where: tok.Where, Name: p.parseId(), Negative: true}
+		return &node.Increment{Where: tok.Where, Target: p.parseTarget(), Negative: true}
 
 	case token.Id:
-		id := p.parseId()
+		target := p.parseTarget()
 		switch p.tok.Type {
 		case token.Assign:
 			p.next()
-			return &node.Assign{Where: p.tok.Where, Name: id, Expr: p.parseExpr()}
+			return &node.Assign{Where: p.tok.Where, Target: target, Expr: p.parseExpr()}
+
+		case token.Colon:
+			if label, ok := target.(*node.Id); ok {
+				p.next()
+				return p.parseLabeled(label)
+			}
 
-		default: return &node.ExprStmt{Expr: id}
+			return &node.ExprStmt{Expr: target}
+
+		default: return &node.ExprStmt{Expr: target}
 		}
 
 	default:
@@ -160,6 +180,100 @@ func (p *Parser) parseIf(invert bool) node.Stmt {
 	return n
 }
 
+func (p *Parser) parseMatch() node.Stmt {
+	n := &node.Match{Where: p.tok.Where}
+
+	p.next()
+	n.Expr = p.parseExpr()
+
+	if p.tok.Type != token.LCurly {
+		goerror.Error(p.tok.Where, "Expected '%v' to open the arms of match, got %v",
+		              token.LCurly, p.tok)
+		return n
+	}
+
+	start := p.tok.Where
+	p.next()
+	for p.tok.Type != token.RCurly {
+		if p.tok.Type == token.EOF {
+			goerror.Error(p.tok.Where, "Expected matching '%v', got %v", token.RCurly, p.tok)
+			goerror.Note(start, "Opened here")
+			return n
+		}
+
+		if p.tok.Type != token.Else {
+			n.Arms = append(n.Arms, p.parseArm())
+			continue
+		}
+
+		if n.Else != nil {
+			goerror.Error(p.tok.Where, "Match can only have one 'else' arm")
+		}
+
+		if p.next(); p.tok.Type != token.Arrow {
+			goerror.Error(p.tok.Where, "Expected '%v' after 'else', got %v", token.Arrow, p.tok)
+			return n
+		}
+
+		p.next()
+		n.Else = p.parseStmts()
+	}
+	p.next()
+	return n
+}
+
+func (p *Parser) parseArm() *node.Arm {
+	n := &node.Arm{Where: p.tok.Where}
+
+	for p.tok.Type != token.Arrow {
+		if p.tok.Type == token.EOF || p.tok.Type == token.RCurly {
+			goerror.Error(p.tok.Where, "Expected '%v' after the values of the arm, got %v",
+			              token.Arrow, p.tok)
+			return n
+		}
+
+		case_ := &node.Case{Lo: p.parseExpr()}
+		if p.tok.Type == token.Range {
+			p.next()
+			case_.Hi = p.parseExpr()
+		}
+
+		n.Cases = append(n.Cases, case_)
+	}
+
+	p.next()
+	n.Body = p.parseStmts()
+	return n
+}
+
+// Loop after a label, like 'outer: while ...'
+func (p *Parser) parseLabeled(label *node.Id) node.Stmt {
+	switch p.tok.Type {
+	case token.While, token.Until:
+		n := p.parseWhile(p.tok.Type == token.Until).(*node.While)
+		n.Label = label
+		return n
+
+	case token.For:
+		n := p.parseFor().(*node.For)
+		n.Label = label
+		return n
+
+	default:
+		goerror.Error(p.tok.Where, "Expected a loop after label '%v', got %v", label.Value, p.tok)
+		return p.parseStmt()
+	}
+}
+
+// Label of a 'break' or 'continue', it has to be on the same line
+func (p *Parser) parseJumpLabel(jump token.Token) *node.Id {
+	if p.tok.Type != token.Id || p.tok.Where.Row != jump.Where.Row {
+		return nil
+	}
+
+	return p.parseId()
+}
+
 func (p *Parser) parseWhile(invert bool) node.Stmt {
 	n := &node.While{Where: p.tok.Where, Invert: invert}
 
@@ -204,7 +318,7 @@ func (p *Parser) parseLet() *node.Let {
 	if p.tok.Type == token.Colon {
 		p.next()
 
-		n.Type = p.parseId()
+		n.Type = p.parseType()
 	}
 
 	if p.tok.Type != token.Assign {
@@ -219,8 +333,13 @@ func (p *Parser) parseLet() *node.Let {
 func (p *Parser) parseMacro() *node.Macro {
 	n := &node.Macro{Where: p.tok.Where}
 
-	p.next()
-	n.Name = p.parseId()
+	if p.next(); p.tok.Type == token.LParen {
+		if !p.parseMacroHead(n) {
+			return n
+		}
+	} else {
+		n.Name = p.parseId()
+	}
 
 	if p.tok.Type != token.Assign {
 		goerror.Error(n.Where, "Macro expression expected")
@@ -232,6 +351,31 @@ func (p *Parser) parseMacro() *node.Macro {
 	return n
 }
 
+func (p *Parser) parseMacroHead(n *node.Macro) bool {
+	start := p.tok.Where
+
+	p.next()
+	n.Name = p.parseId()
+
+	for p.tok.Type != token.RParen {
+		if p.tok.Type == token.EOF {
+			goerror.Error(p.tok.Where, "Expected matching '%v', got %v", token.RParen, p.tok)
+			goerror.Note(start, "Opened here")
+			return false
+		}
+
+		n.Params = append(n.Params, p.parseId())
+	}
+	p.next()
+
+	if len(n.Params) == 0 {
+		goerror.Error(start, "Macro '%v' has parentheses but no parameters", n.Name.Value)
+		goerror.NoteSuggestNewCode(start, "Suggestion: leave out the parentheses",
+		                           []string{"macro " + n.Name.Value + " = ..."})
+	}
+	return true
+}
+
 func (p *Parser) parseId() *node.Id {
 	if p.tok.Type != token.Id {
 		goerror.Error(p.tok.Where, "Expected identifier, got %v", p.tok)
@@ -242,46 +386,97 @@ func (p *Parser) parseId() *node.Id {
 	return &node.Id{Where: tok.Where, Value: tok.Data}
 }
 
-func (p *Parser) parseExpr() (expr node.Expr) {
-	tok := p.tok
-	switch p.tok.Type {
-	case token.LParen: return p.parseFuncCall()
-	case token.Id:     return p.parseId()
+func (p *Parser) parseType() *node.Type {
+	if p.tok.Type == token.LSquare {
+		return p.parseArrayType()
+	} else if p.tok.Type == token.Id && strings.HasPrefix(p.tok.Data, "*") {
+		return p.parsePointerType()
+	}
 
-	case token.Dec:
-		num, err := strconv.ParseInt(p.tok.Data, 10, 64)
-		if err != nil {
-			panic(err)
-		}
+	id := p.parseId()
+	if p.tok.Type != token.Dot {
+		return &node.Type{Where: id.Where, Name: id.Value}
+	}
+
+	p.next()
+	name := p.parseId()
+	return &node.Type{Where: name.Where, Module: id, Name: name.Value}
+}
 
-		expr = &node.Int{Where: tok.Where, Value: num}
+// '*' is an identifier character, so the star is lexed together with the name after it
+func (p *Parser) parsePointerType() *node.Type {
+	n := &node.Type{Where: p.tok.Where, Pointer: true}
 
-	case token.Hex:
-		num, err := strconv.ParseInt(p.tok.Data, 16, 64)
-		if err != nil {
-			panic(err)
-		}
+	if p.tok.Data == "*" {
+		p.next()
+	} else {
+		p.tok.Data = p.tok.Data[1:]
+		p.tok.Where.Col ++
+		p.tok.Where.Len --
+	}
 
-		expr = &node.Int{Where: tok.Where, Value: num}
+	n.Elem = p.parseType()
+	return n
+}
 
-	case token.Oct:
-		num, err := strconv.ParseInt(p.tok.Data, 8, 64)
-		if err != nil {
-			panic(err)
-		}
+func (p *Parser) parseArrayType() *node.Type {
+	n := &node.Type{Where: p.tok.Where}
 
-		expr = &node.Int{Where: tok.Where, Value: num}
+	p.next()
+	if length, ok := p.parseExpr().(*node.Int); ok {
+		n.Len = length.Value
+	} else {
+		goerror.Error(p.prev.Where, "Expected the array length")
+	}
 
-	case token.Bin:
-		num, err := strconv.ParseInt(p.tok.Data, 2, 64)
+	if p.tok.Type != token.RSquare {
+		goerror.Error(p.tok.Where, "Expected '%v' after the array length, got %v",
+		              token.RSquare, p.tok)
+	}
+	p.next()
+
+	n.Elem = p.parseType()
+	return n
+}
+
+// Integers too big for an 'int' keep their bits, the checker decides if they fit into their type
+func (p *Parser) parseInt(base int) *node.Int {
+	n := &node.Int{Where: p.tok.Where}
+	if num, err := strconv.ParseInt(p.tok.Data, base, 64); err == nil {
+		n.Value = num
+	} else if num, err := strconv.ParseUint(p.tok.Data, base, 64); err == nil {
+		n.Value    = int64(num)
+		n.Unsigned = true
+	} else {
+		goerror.Error(p.tok.Where, "Integer '%v' does not fit into 64 bits", p.tok.Data)
+	}
+
+	return n
+}
+
+func (p *Parser) parseExpr() (expr node.Expr) {
+	tok := p.tok
+	switch p.tok.Type {
+	case token.LParen:  return p.parsePostfix(p.parseFuncCall())
+	case token.Id:      return p.parsePostfix(p.parseId())
+	case token.LSquare: return p.parsePostfix(p.parseArray())
+
+	case token.Dec: expr = p.parseInt(10)
+	case token.Hex: expr = p.parseInt(16)
+	case token.Oct: expr = p.parseInt(8)
+	case token.Bin: expr = p.parseInt(2)
+
+	case token.Float:
+		num, err := strconv.ParseFloat(p.tok.Data, 64)
 		if err != nil {
-			panic(err)
+			goerror.Error(tok.Where, "Invalid floating point number '%v'", tok.Data)
 		}
 
-		expr = &node.Int{Where: tok.Where, Value: num}
+		expr = &node.Float{Where: tok.Where, Value: num}
 
 	case token.True:   expr = &node.Bool{Where: tok.Where, Value: true}
 	case token.False:  expr = &node.Bool{Where: tok.Where, Value: false}
+	case token.Char:   expr = &node.Char{Where: tok.Where, Value: tok.Data[0]}
 	case token.String: expr = &node.String{Where: tok.Where, Value: tok.Data}
 
 	default: goerror.Error(p.tok.Where, "Unexpected %v", p.tok)
@@ -291,6 +486,88 @@ func (p *Parser) parseExpr() (expr node.Expr) {
 	return
 }
 
+// Whether the current token directly follows the previous one, without any whitespace
+func (p *Parser) adjacent() bool {
+	return p.tok.Where.Row == p.prev.Where.Row &&
+	       p.tok.Where.Col == p.prev.Where.Col + p.prev.Where.Len
+}
+
+// Parses the field accesses and indexing following an expression, like 'a.b[2].c'. Indexing has
+// to directly follow the expression, so '(f a [1 2])' still passes an array to 'f'
+func (p *Parser) parsePostfix(expr node.Expr) node.Expr {
+	for {
+		switch {
+		case p.tok.Type == token.Dot:
+			n := &node.FieldAccess{Where: p.tok.Where, Expr: expr}
+
+			p.next()
+			n.Field = p.parseId()
+			expr    = n
+
+		case p.tok.Type == token.LSquare && p.adjacent():
+			var ok bool
+			if expr, ok = p.parseIndex(expr); !ok {
+				return expr
+			}
+
+		default: return expr
+		}
+	}
+}
+
+// Parses an index 'x[i]' or a slice 'x[start:end]', in which both of the bounds are optional
+func (p *Parser) parseIndex(expr node.Expr) (node.Expr, bool) {
+	where := p.tok.Where
+
+	p.next()
+	var index node.Expr
+	if p.tok.Type != token.Colon {
+		index = p.parseExpr()
+	}
+
+	if p.tok.Type == token.Colon {
+		n := &node.Slice{Where: where, Expr: expr, Start: index}
+
+		p.next()
+		if p.tok.Type != token.RSquare {
+			n.End = p.parseExpr()
+		}
+
+		expr = n
+	} else {
+		expr = &node.Index{Where: where, Expr: expr, Index: index}
+	}
+
+	if p.tok.Type != token.RSquare {
+		goerror.Error(p.tok.Where, "Expected '%v' to close the index, got %v", token.RSquare, p.tok)
+		return expr, false
+	}
+	p.next()
+	return expr, true
+}
+
+// Parses a variable, or a field or an element of a variable
+func (p *Parser) parseTarget() node.Expr {
+	return p.parsePostfix(p.parseId())
+}
+
+func (p *Parser) parseArray() *node.Array {
+	n := &node.Array{Where: p.tok.Where}
+
+	p.next()
+	for p.tok.Type != token.RSquare {
+		if p.tok.Type == token.EOF {
+			goerror.Error(p.tok.Where, "Expected matching '%v', got %v", token.RSquare, p.tok)
+			goerror.Note(n.Where, "Opened here")
+			return n
+		}
+
+		n.Elems = append(n.Elems, p.parseExpr())
+	}
+	p.next()
+	return n
+}
+
 func (p *Parser) parseFuncCall() *node.FuncCall {
 	n := &node.FuncCall{Where: p.tok.Where}
 
@@ -298,6 +575,13 @@ func (p *Parser) parseFuncCall() *node.FuncCall {
 	p.next()
 	n.Name = p.parseId()
 
+	if p.tok.Type == token.Dot {
+		p.next()
+
+		n.Module = n.Name
+		n.Name   = p.parseId()
+	}
+
 	for p.tok.Type != token.RParen {
 		if p.tok.Type == token.EOF {
 			goerror.Error(p.tok.Where, "Expected matching '%v', got %v", token.RParen, p.tok)
@@ -316,7 +600,7 @@ var attrsMap = map[token.Type]int{
 	token.Interrupt: node.AttrInterrupt,
 }
 
-func (p *Parser) parseAttrs() (attrs int) {
+func (p *Parser) parseAttrs(n *node.Func) {
 	p.next()
 	for p.tok.Type != token.RSquare {
 		attr, ok := attrsMap[p.tok.Type]
@@ -324,11 +608,26 @@ func (p *Parser) parseAttrs() (attrs int) {
 			goerror.Error(p.tok.Where, "Expected an attribute, got %v", p.tok)
 		}
 
-		attrs |= attr
+		n.Attrs |= attr
 		p.next()
+
+		if attr == node.AttrInterrupt {
+			n.Interrupt = p.parseInterruptNum()
+		}
 	}
 	p.next()
-	return
+}
+
+func (p *Parser) parseInterruptNum() *node.Int {
+	switch p.tok.Type {
+	case token.Dec, token.Hex, token.Oct, token.Bin: return p.parseExpr().(*node.Int)
+
+	default:
+		goerror.Error(p.tok.Where, "Expected the number of the interrupt, got %v", p.tok)
+		goerror.NoteSuggestNewCode(p.tok.Where, "Suggestion: bind the handler to an interrupt",
+		                           []string{"proc (handler) [interrupt 1] {"})
+		return nil
+	}
 }
 
 func (p *Parser) parseFunc() *node.Func {
@@ -352,30 +651,148 @@ func (p *Parser) parseFunc() *node.Func {
 			return nil
 		}
 
-		// TODO: Function definition arguments
-
-		p.next()
+		n.Params = append(n.Params, p.parseParam())
 	}
 	p.next()
 
 	if p.tok.Type == token.LSquare {
-		n.Attrs = p.parseAttrs()
+		p.parseAttrs(n)
 	}
 
 	if p.tok.Type == token.Arrow {
 		p.next()
-		n.Type = p.parseId()
+		n.Type = p.parseType()
 	}
 
  	n.Body = p.parseStmts()
 	return n
 }
 
+func (p *Parser) parseParam() *node.Param {
+	n := &node.Param{Where: p.tok.Where}
+
+	n.Name = p.parseId()
+	if p.tok.Type != token.Colon {
+		goerror.Error(p.tok.Where, "Expected '%v' after parameter '%v', got %v",
+		              token.Colon, n.Name.Value, p.tok)
+		return n
+	}
+
+	p.next()
+	n.Type = p.parseType()
+	return n
+}
+
+func (p *Parser) parseModule() *node.Module {
+	n := &node.Module{Where: p.tok.Where}
+
+	p.next()
+	n.Name = p.parseId()
+	return n
+}
+
+func (p *Parser) parseImport() *node.Import {
+	n := &node.Import{Where: p.tok.Where}
+
+	p.next()
+	if p.tok.Type != token.String {
+		goerror.Error(p.tok.Where, "Expected the path of the imported module, got %v", p.tok)
+		p.next()
+		return n
+	}
+
+	n.Path = &node.String{Where: p.tok.Where, Value: p.tok.Data}
+	p.next()
+	return n
+}
+
+func (p *Parser) parseStruct() *node.Struct {
+	n := &node.Struct{Where: p.tok.Where}
+
+	p.next()
+	n.Name = p.parseId()
+
+	if p.tok.Type != token.LCurly {
+		goerror.Error(p.tok.Where, "Expected '%v' to open structure '%v', got %v",
+		              token.LCurly, n.Name.Value, p.tok)
+		return n
+	}
+
+	start := p.tok.Where
+	p.next()
+	for p.tok.Type != token.RCurly {
+		if p.tok.Type == token.EOF {
+			goerror.Error(p.tok.Where, "Expected matching '%v', got %v", token.RCurly, p.tok)
+			goerror.Note(start, "Opened here")
+			return n
+		}
+
+		n.Fields = append(n.Fields, p.parseField())
+	}
+	p.next()
+	return n
+}
+
+func (p *Parser) parseField() *node.Field {
+	n := &node.Field{Where: p.tok.Where}
+
+	n.Name = p.parseId()
+	if p.tok.Type != token.Colon {
+		goerror.Error(p.tok.Where, "Expected '%v' after field '%v', got %v",
+		              token.Colon, n.Name.Value, p.tok)
+		p.next()
+		return n
+	}
+
+	p.next()
+	n.Type = p.parseType()
+	return n
+}
+
+func (p *Parser) parseEnum() *node.Enum {
+	n := &node.Enum{Where: p.tok.Where}
+
+	p.next()
+	n.Name = p.parseId()
+
+	if p.tok.Type != token.LCurly {
+		goerror.Error(p.tok.Where, "Expected '%v' to open enumeration '%v', got %v",
+		              token.LCurly, n.Name.Value, p.tok)
+		return n
+	}
+
+	start := p.tok.Where
+	p.next()
+	for p.tok.Type != token.RCurly {
+		if p.tok.Type == token.EOF {
+			goerror.Error(p.tok.Where, "Expected matching '%v', got %v", token.RCurly, p.tok)
+			goerror.Note(start, "Opened here")
+			return n
+		}
+
+		n.Members = append(n.Members, p.parseMember())
+	}
+	p.next()
+	return n
+}
+
+func (p *Parser) parseMember() *node.Member {
+	n := &node.Member{Where: p.tok.Where}
+
+	n.Name = p.parseId()
+	if p.tok.Type == token.Assign {
+		p.next()
+		n.Value = p.parseExpr()
+	}
+	return n
+}
+
 func (p *Parser) next() {
 	if p.tok.Type == token.EOF {
 		return
 	}
 
+	p.prev = p.tok
 	if p.tok = p.l.NextToken(); p.tok.Type == token.Error {
 		goerror.Error(p.tok.Where, p.tok.Data)
 		os.Exit(1)
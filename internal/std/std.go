@@ -0,0 +1,23 @@
+// Modules of the standard library, bundled into the compiler
+package std
+
+import (
+	"embed"
+	"path"
+	"path/filepath"
+)
+
+// Directory the standard library modules appear to be in, in paths and error messages
+const Dir = "<std>"
+
+//go:embed *.rsl
+var modules embed.FS
+
+func Has(name string) bool {
+	_, err := modules.Open(path.Clean(filepath.ToSlash(name)))
+	return err == nil
+}
+
+func Read(name string) ([]byte, error) {
+	return modules.ReadFile(path.Clean(name))
+}
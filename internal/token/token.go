@@ -28,6 +28,7 @@ const (
 	Hex
 	Oct
 	Bin
+	Float
 	Char
 
 	True
@@ -51,6 +52,7 @@ const (
 	Arrow
 	Colon
 	Dot
+	Range
 
 	Module
 	Import
@@ -58,6 +60,8 @@ const (
 	Macro
 	Let
 	Proc
+	Struct
+	Enum
 
 	Inline
 	Interrupt
@@ -65,6 +69,7 @@ const (
 	If
 	Unless
 	Else
+	Match
 
 	While
 	Until
@@ -85,10 +90,12 @@ var tokTypeNames = map[Type]string{
 
 	Id: "identifier",
 
-	Dec:  "decimal number",
-	Hex:  "hexadecimal number",
-	Oct:  "octal number",
-	Char: "character",
+	Dec:   "decimal number",
+	Hex:   "hexadecimal number",
+	Oct:   "octal number",
+	Bin:   "binary number",
+	Float: "floating point number",
+	Char:  "character",
 
 	True:  "true",
 	False: "false",
@@ -109,15 +116,18 @@ var tokTypeNames = map[Type]string{
 	Decrement: "--",
 
 	Dot:   ".",
+	Range: "..",
 	Arrow: "->",
 	Colon: ":",
 
 	Module: "keyword module",
 	Import: "keyword import",
 
-	Macro: "keyword mac",
-	Let:   "keyword let",
-	Proc:  "keyword proc",
+	Macro:  "keyword mac",
+	Let:    "keyword let",
+	Proc:   "keyword proc",
+	Struct: "keyword struct",
+	Enum:   "keyword enum",
 
 	Inline:    "keyword inline",
 	Interrupt: "keyword interrupt",
@@ -125,6 +135,7 @@ var tokTypeNames = map[Type]string{
 	If:     "keyword if",
 	Unless: "keyword unless",
 	Else:   "keyword else",
+	Match:  "keyword match",
 
 	While:    "keyword while",
 	Until:    "keyword until",
@@ -138,7 +149,7 @@ var tokTypeNames = map[Type]string{
 }
 
 func AllTokensCoveredTest() {
-	if count != 40 {
+	if count != 45 {
 		panic("Cover all token types")
 	}
 }
@@ -163,7 +174,8 @@ func (t Token) String() string {
 	switch t.Type {
 	case EOF: return "'end of file'"
 
-	case Id, Dec, Hex, Oct, Char, String: return fmt.Sprintf("'%v' of type '%v'", t.Data, t.Type)
+	case Id, Dec, Hex, Oct, Bin, Float, Char, String:
+		return fmt.Sprintf("'%v' of type '%v'", t.Data, t.Type)
 
 	default: return fmt.Sprintf("'%v'", t.Type)
 	}
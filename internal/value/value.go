@@ -2,20 +2,241 @@ package value
 
 import "fmt"
 
-type Type int
+// Size of a stack word in bytes
+const WordSize = 8
+
+type Kind int
 const (
-	Void = iota
-	Int
-	Bool
-	String
+	KindInvalid = Kind(iota) // Type of expressions that already caused an error
+	KindVoid
+	KindInt
+	KindFloat
+	KindBool
+	KindChar
+	KindString
+	KindStruct
+	KindEnum
+	KindArray
+	KindPointer
 )
 
-func (t Type) String() string {
-	switch t {
-	case Int:    return "int"
-	case Bool:   return "bool"
-	case String: return "string"
+type Field struct {
+	Name   string
+	Type   *Type
+	Offset int
+}
+
+// Named constant of an enumeration
+type Member struct {
+	Name  string
+	Value int64
+}
+
+type Type struct {
+	Kind Kind
+	Name string
+
+	Fields  []*Field  // Structures
+	Members []*Member // Enumerations
+
+	Elem *Type // Arrays and pointers, nil for raw pointers
+	Len   int
+
+	// Integers are read and written with their width in bytes, but take up a word like the other
+	// types, so structures and arrays stay aligned
+	Width    int
+	Unsigned bool
+
+	size int
+}
+
+var (
+	Void   = &Type{Kind: KindVoid,    Name: "void"}
+	Int    = &Type{Kind: KindInt,     Name: "int",    size: WordSize, Width: 8}
+	Float  = &Type{Kind: KindFloat,   Name: "float",  size: WordSize}
+	Bool   = &Type{Kind: KindBool,    Name: "bool",   size: WordSize}
+	Char   = &Type{Kind: KindChar,    Name: "char",   size: WordSize}
+	String = &Type{Kind: KindString,  Name: "string", size: WordSize * 2}
+	Ptr    = &Type{Kind: KindPointer, Name: "ptr",    size: WordSize} // Raw pointer
+
+	I8  = &Type{Kind: KindInt, Name: "i8",  size: WordSize, Width: 1}
+	I16 = &Type{Kind: KindInt, Name: "i16", size: WordSize, Width: 2}
+	I32 = &Type{Kind: KindInt, Name: "i32", size: WordSize, Width: 4}
+	U8  = &Type{Kind: KindInt, Name: "u8",  size: WordSize, Width: 1, Unsigned: true}
+	U16 = &Type{Kind: KindInt, Name: "u16", size: WordSize, Width: 2, Unsigned: true}
+	U32 = &Type{Kind: KindInt, Name: "u32", size: WordSize, Width: 4, Unsigned: true}
+	U64 = &Type{Kind: KindInt, Name: "u64", size: WordSize, Width: 8, Unsigned: true}
+)
+
+var typeNames = map[string]*Type{
+	"void":   Void,
+	"int":    Int,
+	"float":  Float,
+	"bool":   Bool,
+	"char":   Char,
+	"string": String,
+	"ptr":    Ptr,
+
+	"i8":  I8,
+	"i16": I16,
+	"i32": I32,
+	"i64": Int,
+	"u8":  U8,
+	"u16": U16,
+	"u32": U32,
+	"u64": U64,
+}
+
+func FromName(name string) (*Type, bool) {
+	t, ok := typeNames[name]
+	return t, ok
+}
+
+func Names() (names []string) {
+	for name, _ := range typeNames {
+		names = append(names, name)
+	}
+	return
+}
+
+type arrayKey struct {
+	elem *Type
+	len   int
+}
+
+// Array types are shared, so they can be compared like the other types
+var arrays = make(map[arrayKey]*Type)
+
+func ArrayOf(elem *Type, len int) *Type {
+	key := arrayKey{elem: elem, len: len}
+	if t, ok := arrays[key]; ok {
+		return t
+	}
+
+	t := &Type{Kind: KindArray, Name: fmt.Sprintf("[%v]%v", len, elem), Elem: elem, Len: len}
+	arrays[key] = t
+	return t
+}
+
+var pointers = make(map[*Type]*Type)
+
+func PointerTo(elem *Type) *Type {
+	if t, ok := pointers[elem]; ok {
+		return t
+	}
+
+	t := &Type{Kind: KindPointer, Name: "*" + elem.String(), Elem: elem, size: WordSize}
+	pointers[elem] = t
+	return t
+}
+
+// Creates a structure without fields, they are added once their types are known
+func NewStruct(name string) *Type {
+	return &Type{Kind: KindStruct, Name: name}
+}
+
+// Fields are laid out in memory in the order they are added
+func (t *Type) AddField(name string, type_ *Type) *Field {
+	field := &Field{Name: name, Type: type_, Offset: t.size}
+	t.Fields = append(t.Fields, field)
+	t.size  += type_.Size()
+	return field
+}
+
+func (t *Type) Field(name string) *Field {
+	for _, field := range t.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+
+	return nil
+}
+
+func (t *Type) FieldNames() (names []string) {
+	for _, field := range t.Fields {
+		names = append(names, field.Name)
+	}
+	return
+}
+
+// Enumerations are stored like integers, but are distinct types
+func NewEnum(name string) *Type {
+	return &Type{Kind: KindEnum, Name: name, size: WordSize}
+}
+
+func (t *Type) AddMember(name string, value int64) *Member {
+	member := &Member{Name: name, Value: value}
+	t.Members = append(t.Members, member)
+	return member
+}
+
+func (t *Type) Member(name string) *Member {
+	for _, member := range t.Members {
+		if member.Name == name {
+			return member
+		}
+	}
+
+	return nil
+}
+
+func (t *Type) MemberNames() (names []string) {
+	for _, member := range t.Members {
+		names = append(names, member.Name)
+	}
+	return
+}
+
+// Size in bytes. The size of an array is not stored, because the structure it holds may not be
+// laid out yet when the array type is created
+func (t *Type) Size() int {
+	if t.Kind == KindArray {
+		return t.Len * t.Elem.Size()
+	}
+
+	return t.size
+}
+
+// Integers narrower than a word, which have to be truncated to their width
+func (t *Type) Narrow() bool {
+	return t.Kind == KindInt && t.Width < WordSize
+}
+
+// Offset of the bytes of a value in the word it takes up, memory is big endian
+func (t *Type) ByteOffset() int {
+	if t.Narrow() {
+		return WordSize - t.Width
+	}
+
+	return 0
+}
+
+func (t *Type) InRange(v int64) bool {
+	if !t.Narrow() {
+		return !t.Unsigned || v >= 0
+	}
+
+	bits := uint(t.Width * 8)
+	if t.Unsigned {
+		return v >= 0 && v < 1 << bits
+	}
+
+	return v >= -(1 << (bits - 1)) && v < 1 << (bits - 1)
+}
+
+// Count of stack words a value of the type takes up
+func (t *Type) Words() int {
+	return t.Size() / WordSize
+}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case KindInvalid: return "invalid"
+	case KindVoid, KindInt, KindFloat, KindBool, KindChar, KindString, KindStruct, KindEnum,
+	     KindArray, KindPointer:
+		return t.Name
 
-	default: panic(fmt.Errorf("Unknown type value %v", int(t)))
+	default: panic(fmt.Errorf("Unknown type kind %v", int(t.Kind)))
 	}
 }